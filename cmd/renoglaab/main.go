@@ -7,7 +7,7 @@ import (
 )
 
 func main() {
-	if err := app.Run(); err != nil {
+	if err := app.Execute(os.Args[1:]); err != nil {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,485 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/mergerequests"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+)
+
+var (
+	errUnknownCommand = errors.New("unknown command")
+	errUsage          = errors.New("wrong number of arguments")
+	errInvalidIID     = errors.New("invalid merge request IID")
+	errGitLabOnly     = errors.New("command is only supported with PROVIDER=gitlab")
+	errNoPolicy       = errors.New("POLICY is not set")
+	errPolicyTest     = errors.New("policy test failed")
+)
+
+// command is a subcommand of the command line interface.
+type command struct {
+	args    []string
+	summary string
+	run     func(cfg *config.Config, args []string) error
+}
+
+const commandValidate = "validate"
+
+var commands = map[string]command{
+	"run": {
+		summary: "Reconcile the merge requests of every repository (default)",
+		run:     func(cfg *config.Config, _ []string) error { return Run(cfg) },
+	},
+	"list": {
+		summary: "List the open merge requests of every repository and whether they would be approved",
+		run:     func(cfg *config.Config, _ []string) error { return list(cfg) },
+	},
+	"explain": {
+		args:    []string{"project", "iid"},
+		summary: "Run every filter on a merge request and print its decision trace",
+		run:     func(cfg *config.Config, args []string) error { return explain(cfg, args[0], args[1]) },
+	},
+	"approve": {
+		args:    []string{"project", "iid"},
+		summary: "Approve a merge request right away if it passes the filters",
+		run:     func(cfg *config.Config, args []string) error { return approve(cfg, args[0], args[1]) },
+	},
+	"test": {
+		args:    []string{"fixtures"},
+		summary: "Evaluate POLICY against the recorded merge requests of a fixture file or directory",
+		run:     func(cfg *config.Config, args []string) error { return testPolicy(cfg, args[0]) },
+	},
+	commandValidate: {
+		summary: "Check the configuration and print every problem found",
+		run:     func(*config.Config, []string) error { return validate() },
+	},
+}
+
+// usage returns the placeholders of the arguments.
+func (c command) usage() string {
+	placeholders := make([]string, 0, len(c.args))
+	for _, arg := range c.args {
+		placeholders = append(placeholders, "<"+arg+">")
+	}
+
+	return strings.Join(placeholders, " ")
+}
+
+// commandOrder is the order the commands are listed in the usage.
+var commandOrder = []string{"run", "list", "explain", "approve", "test", commandValidate}
+
+// Execute runs the command named by the first argument, "run" if there is none.
+// Every setting can be given as a flag after the command, taking precedence over its environment variable.
+func Execute(args []string) error {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		usage()
+
+		return nil
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "%s %q\n\n", errUnknownCommand, name)
+		usage()
+
+		return errUnknownCommand
+	}
+
+	fs := flag.NewFlagSet("renoglaab "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: renoglaab %s [flags] %s\n\n%s.\n\nFlags:\n",
+			name, cmd.usage(), cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	flags := config.Flags(fs)
+
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+
+		return err
+	}
+
+	if len(args) != len(cmd.args) {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n\n", name, errUsage)
+		fs.Usage()
+
+		return errUsage
+	}
+
+	cfg, err := config.NewConfigWithFlags(flags)
+	err = errors.Join(err, mergerequests.ValidateFilterOrder(cfg.FilterOrder), mergerequests.ValidatePolicy(cfg.Policy))
+
+	if name != commandValidate {
+		err = withoutNearMisses(err)
+	}
+
+	if err != nil {
+		if name == commandValidate {
+			_, _ = fmt.Fprintln(os.Stdout, "Configuration is invalid:")
+
+			for _, problem := range problems(err) {
+				_, _ = fmt.Fprintln(os.Stdout, "  -", problem)
+			}
+		} else {
+			for _, problem := range problems(err) {
+				logrus.Error(problem)
+			}
+		}
+
+		return err
+	}
+
+	return cmd.run(cfg, args)
+}
+
+// withoutNearMisses logs the variables that only look like a misspelled setting as warnings and returns the other problems.
+// The environment is shared with everything else, so only validate stops on a near miss.
+func withoutNearMisses(err error) error {
+	var rest []error
+
+	for _, problem := range problems(err) {
+		if errors.Is(problem, config.ErrUnknownSetting) {
+			logrus.Warn(problem)
+
+			continue
+		}
+
+		rest = append(rest, problem)
+	}
+
+	return errors.Join(rest...)
+}
+
+// parseArgs parses the flags on fs and returns the positional arguments.
+// Unlike fs.Parse, flags may follow the arguments, e.g. "explain group/project 42 --log-level=debug".
+// Everything after "--" is taken as an argument.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		positional, args = append(positional, rest[0]), rest[1:]
+	}
+}
+
+// usage prints the available commands.
+func usage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: renoglaab [command] [flags] [arguments]\n\nCommands:")
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, name := range commandOrder {
+		cmd := commands[name]
+		_, _ = fmt.Fprintf(w, "  %s %s\t%s\n", name, cmd.usage(), cmd.summary)
+	}
+
+	_ = w.Flush()
+
+	_, _ = fmt.Fprintln(os.Stderr, "\nRun \"renoglaab <command> -h\" to list the flags.")
+}
+
+// problems splits the joined configuration problems.
+func problems(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+
+	var all []error
+	for _, problem := range joined.Unwrap() {
+		all = append(all, problems(problem)...)
+	}
+
+	return all
+}
+
+// validate is only reached if the configuration was loaded without problems.
+func validate() error {
+	_, _ = fmt.Fprintln(os.Stdout, "Configuration is valid")
+
+	return nil
+}
+
+// list prints the open merge requests of every repository and why they would or wouldn't be approved.
+func list(cfg *config.Config) error {
+	if cfg.Provider != config.ProviderGitLab {
+		return errGitLabOnly
+	}
+
+	repositories, err := gl.GetRepositories(cfg)
+	if err != nil {
+		logrus.WithError(err).Error(errFailedToExtractRepositories.Error())
+
+		return err
+	}
+
+	clients, err := gitLabClients(cfg, repositories)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Preflight {
+		repositories = newPreflightChecks(repositories, clients).passed
+	}
+
+	var traces []*mergerequests.Trace
+
+	for _, repo := range repositories {
+		instance, _ := gl.SplitRepository(repo)
+		traces = append(traces, mergerequests.EvaluateProject(*cfg, repo, clients[instance])...)
+	}
+
+	if cfg.Output == config.OutputJSON {
+		return writeJSON(traces)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "REPOSITORY\tMR\tSTATUS\tTITLE")
+
+	for _, trace := range traces {
+		status := "approvable"
+		if !trace.Approvable {
+			status = fmt.Sprintf("%s: %s", trace.RejectedBy, trace.Reason)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t!%d\t%s\t%s\n", trace.Repository, trace.IID, status, trace.Title)
+	}
+
+	return w.Flush()
+}
+
+// explain prints the decision trace of a merge request, running every filter even after one rejected it.
+func explain(cfg *config.Config, repo, iid string) error {
+	client, mrIID, err := mergeRequestClient(cfg, repo, iid)
+	if err != nil {
+		return err
+	}
+
+	trace, err := mergerequests.EvaluateMergeRequest(*cfg, repo, mrIID, client)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"repository": repo, "mrID": mrIID}).Error("Failed to evaluate merge request")
+
+		return err
+	}
+
+	if cfg.Output == config.OutputJSON {
+		return writeJSON(trace)
+	}
+
+	return trace.WriteText(os.Stdout)
+}
+
+// writeJSON prints v as indented JSON.
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+// testPolicy evaluates POLICY against the fixtures of a file, or of every JSON file in a directory,
+// and prints whether each decision matches the expected one.
+func testPolicy(cfg *config.Config, path string) error {
+	if cfg.Policy == "" {
+		return errNoPolicy
+	}
+
+	fixtures, err := readFixtures(path)
+	if err != nil {
+		logrus.WithError(err).WithField("path", path).Error("Failed to read policy fixtures")
+
+		return err
+	}
+
+	decision := map[bool]string{true: "approved", false: "denied"}
+	failures := 0
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	for _, fixture := range fixtures {
+		status, result := "ok", ""
+
+		approved, err := mergerequests.EvaluatePolicy(cfg.Policy, fixture.Input)
+
+		switch {
+		case err != nil:
+			status, result = "FAIL", err.Error()
+		case approved != fixture.Expect:
+			status, result = "FAIL", fmt.Sprintf("%s, expected %s", decision[approved], decision[fixture.Expect])
+		default:
+			result = decision[approved]
+		}
+
+		if status != "ok" {
+			failures++
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", status, fixture.Name, result)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failures > 0 {
+		err := fmt.Errorf("%w: %d of %d fixtures failed", errPolicyTest, failures, len(fixtures))
+		_, _ = fmt.Fprintln(os.Stdout, err)
+
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d fixtures passed\n", len(fixtures))
+
+	return nil
+}
+
+// readFixtures reads the policy fixtures of a file, or of every JSON file in a directory.
+// A file holds a single fixture or a list of them. Fixtures without a name are named after their file and position.
+func readFixtures(path string) ([]mergerequests.PolicyFixture, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, err
+		}
+	}
+
+	var fixtures []mergerequests.PolicyFixture
+
+	for _, file := range files {
+		data, err := os.ReadFile(file) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+
+		var decoded []mergerequests.PolicyFixture
+
+		data = bytes.TrimSpace(data)
+		if bytes.HasPrefix(data, []byte("[")) {
+			err = json.Unmarshal(data, &decoded)
+		} else {
+			decoded = make([]mergerequests.PolicyFixture, 1)
+			err = json.Unmarshal(data, &decoded[0])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		for i := range decoded {
+			if decoded[i].Name == "" && len(decoded) > 1 {
+				decoded[i].Name = fmt.Sprintf("%s[%d]", file, i)
+			} else if decoded[i].Name == "" {
+				decoded[i].Name = file
+			}
+		}
+
+		fixtures = append(fixtures, decoded...)
+	}
+
+	return fixtures, nil
+}
+
+// approve approves a merge request right away if it passes the filters.
+func approve(cfg *config.Config, repo, iid string) error {
+	client, mrIID, err := mergeRequestClient(cfg, repo, iid)
+	if err != nil {
+		return err
+	}
+
+	fields := logrus.Fields{"repository": repo, "mrID": mrIID}
+
+	// The GitLab state backend lives on the default instance, which the client of the repository may not be.
+	var stateClient *gl.ClientWrapper
+	if cfg.StateBackend == config.StateBackendGitLab {
+		if stateClient, err = gl.CreateDefaultClient(cfg); err != nil {
+			logrus.WithError(err).Error("Failed to create GitLab client")
+
+			return err
+		}
+	}
+
+	store, err := state.Open(newStateBackend(cfg, stateClient))
+	if err != nil {
+		logrus.WithError(err).WithField("backend", cfg.StateBackend).Error("Failed to load state")
+
+		return err
+	}
+
+	approved, err := mergerequests.ApproveMergeRequest(*cfg, repo, mrIID, client, store)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to approve merge request")
+
+		return err
+	}
+
+	if !approved {
+		logrus.WithFields(fields).Info("Merge request is already approved")
+	}
+
+	if err := store.Save(); err != nil {
+		logrus.WithError(err).WithField("backend", cfg.StateBackend).Error("Failed to save state")
+
+		return err
+	}
+
+	return nil
+}
+
+// mergeRequestClient returns the client for the instance of a repository and the parsed IID.
+func mergeRequestClient(cfg *config.Config, repo, iid string) (*gl.ClientWrapper, int64, error) {
+	if cfg.Provider != config.ProviderGitLab {
+		return nil, 0, errGitLabOnly
+	}
+
+	mrIID, err := strconv.ParseInt(strings.TrimPrefix(iid, "!"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w %q", errInvalidIID, iid)
+	}
+
+	clients, err := gitLabClients(cfg, []string{repo})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	instance, _ := gl.SplitRepository(repo)
+
+	return clients[instance], mrIID, nil
+}
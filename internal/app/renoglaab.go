@@ -1,28 +1,50 @@
 package app
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/xMoelletschi/renoglaab/internal/config"
 	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
 	"github.com/xMoelletschi/renoglaab/internal/mergerequests"
+	"github.com/xMoelletschi/renoglaab/internal/metrics"
+	"github.com/xMoelletschi/renoglaab/internal/provider"
+	"github.com/xMoelletschi/renoglaab/internal/state"
 )
 
-var errFailedToExtractRepositories = errors.New("failed to extract repositories")
+var (
+	errFailedToExtractRepositories = errors.New("failed to extract repositories")
+	errNoRepositoryPassedPreflight = errors.New("no repository passed the preflight checks")
+	errInvalidInterval             = errors.New("INTERVAL must be positive in daemon mode")
+)
 
-const workerCount = 5
+// reconcileFunc reconciles the merge requests of a single repository.
+type reconcileFunc func(repo string)
 
-// Run is the main entry point for executing the application logic.
-// It performs the following steps:
-// 1. Loads the configuration from the config file.
-// 2. Extracts the list of repositories from the configuration.
-// 3. Creates a GitLab client using the provided API token and URL.
-// 4. Iterates over each repository and reconciles the merge requests.
-func Run() error {
-	cfg := config.NewConfig()
+const (
+	workerCount           = 5
+	metricsServerTimeout  = 10 * time.Second
+	metricsShutdownPeriod = 5 * time.Second
+)
 
+// Run reconciles the merge requests of every repository. It performs the following steps:
+// 1. Extracts the list of repositories from the configuration.
+// 2. Creates a client for the configured provider, one per GitLab instance the repositories are on,
+// checks their tokens and skips repositories failing the preflight checks until they pass.
+// 3. Iterates over each repository and reconciles the merge requests.
+// In daemon mode step 3 is repeated every interval and metrics are served on /metrics,
+// otherwise metrics are exported once the run has finished.
+func Run(cfg *config.Config) error {
 	repositories, err := gl.GetRepositories(cfg)
 	if err != nil {
 		logrus.WithError(err).Error(errFailedToExtractRepositories.Error())
@@ -30,13 +52,226 @@ func Run() error {
 		return err
 	}
 
-	gitLabClient, err := gl.CreateGitLabClient(cfg.GitLabAPIToken, cfg.GitLabURL)
+	var (
+		reconcileRepo reconcileFunc
+		recheck       func() []string
+		store         *state.Store
+	)
+
+	switch cfg.Provider {
+	case config.ProviderGitea:
+		store, err = state.Open(newStateBackend(cfg, nil))
+		if err != nil {
+			logrus.WithError(err).WithField("backend", cfg.StateBackend).Error("Failed to load state")
+
+			return err
+		}
+
+		giteaProvider := provider.NewGitea(cfg.GiteaURL, cfg.GiteaAPIToken)
+		reconcileRepo = func(repo string) {
+			mergerequests.ReconcileChangeRequests(*cfg, repo, giteaProvider)
+		}
+	default:
+		clients, err := gitLabClients(cfg, repositories)
+		if err != nil {
+			return err
+		}
+
+		if cfg.Preflight {
+			checks := newPreflightChecks(repositories, clients)
+
+			// A daemon keeps running, GitLab may only be unreachable for now and the failed
+			// repositories are checked again before every pass.
+			if len(checks.passed) == 0 && !cfg.Daemon {
+				logrus.Error(errNoRepositoryPassedPreflight.Error())
+
+				return errNoRepositoryPassedPreflight
+			}
+
+			if len(checks.passed) == 0 {
+				logrus.Warn(errNoRepositoryPassedPreflight.Error() + ", checking them again next interval")
+			}
+
+			repositories, recheck = checks.passed, checks.recheck
+		}
+
+		store, err = state.Open(newStateBackend(cfg, clients[""]))
+		if err != nil {
+			logrus.WithError(err).WithField("backend", cfg.StateBackend).Error("Failed to load state")
+
+			return err
+		}
+
+		// The repository keeps its instance prefix, so the same project path on two instances
+		// doesn't share its state and metrics.
+		reconcileRepo = func(repo string) {
+			instance, _ := gl.SplitRepository(repo)
+			mergerequests.ReconcileProjectMergeRequests(*cfg, repo, clients[instance], store)
+		}
+	}
+
+	if cfg.Daemon {
+		return runDaemon(cfg, repositories, recheck, reconcileRepo, store)
+	}
+
+	reconcile(cfg, repositories, reconcileRepo, store)
+
+	return exportMetrics(cfg)
+}
+
+// gitLabClients creates a client for every GitLab instance the repositories are on and checks their tokens.
+func gitLabClients(cfg *config.Config, repositories []string) (map[string]*gl.ClientWrapper, error) {
+	clients, err := gl.CreateClients(cfg, repositories)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to create GitLab client")
 
+		return nil, err
+	}
+
+	// The GitLab state backend lives on the default instance, even if no repository does.
+	if cfg.StateBackend == config.StateBackendGitLab && clients[""] == nil {
+		clients[""], err = gl.CreateDefaultClient(cfg)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to create GitLab client")
+
+			return nil, err
+		}
+	}
+
+	if err := selfCheck(cfg, clients); err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// selfCheck checks the authentication of every client, in a stable order.
+func selfCheck(cfg *config.Config, clients map[string]*gl.ClientWrapper) error {
+	for _, instance := range slices.Sorted(maps.Keys(clients)) {
+		auth := config.GitLabAuthToken
+		if instance == "" {
+			auth = cfg.GitLabAuth
+		}
+
+		if err := gl.SelfCheck(instance, auth, cfg.TokenExpiryWarning, clients[instance]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// preflightChecks tracks which repositories passed the preflight checks. In daemon mode the failed ones
+// are checked again before every later pass, so a fixed project is picked up without a restart.
+type preflightChecks struct {
+	clients map[string]*gl.ClientWrapper
+	passed  []string
+	failed  []string
+}
+
+// newPreflightChecks checks every repository.
+func newPreflightChecks(repositories []string, clients map[string]*gl.ClientWrapper) *preflightChecks {
+	checks := &preflightChecks{clients: clients}
+	checks.check(repositories)
+
+	return checks
+}
+
+// check checks the repositories, logging the result of each one and a summary of all of them.
+func (p *preflightChecks) check(repositories []string) {
+	var passed, failed []string
+
+	for _, repo := range repositories {
+		instance, _ := gl.SplitRepository(repo)
+		fields := logrus.Fields{"repository": repo}
+
+		if err := mergerequests.PreflightProject(repo, p.clients[instance]); err != nil {
+			logrus.WithError(err).WithFields(fields).Error("Preflight check failed, skipping repository")
+
+			failed = append(failed, repo)
+
+			continue
+		}
+
+		logrus.WithFields(fields).Info("Preflight check passed")
+
+		passed = append(passed, repo)
+	}
+
+	logrus.WithFields(logrus.Fields{"passed": passed, "failed": failed}).Info("Preflight checks finished")
+
+	p.passed = append(p.passed, passed...)
+	p.failed = failed
+}
+
+// recheck checks the failed repositories again and returns every repository that passed so far.
+func (p *preflightChecks) recheck() []string {
+	if len(p.failed) > 0 {
+		p.check(p.failed)
+	}
+
+	return p.passed
+}
+
+// runDaemon reconciles the repositories every interval until the process is interrupted.
+// If set, recheck is called before every later pass and returns the repositories to reconcile.
+func runDaemon(
+	cfg *config.Config, repositories []string, recheck func() []string, reconcileRepo reconcileFunc, store *state.Store,
+) error {
+	// The configuration rejects it already, but a ticker would panic on it.
+	if cfg.Interval <= 0 {
+		err := fmt.Errorf("%w, got %s", errInvalidInterval, cfg.Interval)
+		logrus.Error(err.Error())
+
 		return err
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	server := &http.Server{
+		Addr:              cfg.MetricsAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: metricsServerTimeout,
+	}
+
+	go func() {
+		logrus.WithField("address", cfg.MetricsAddress).Info("Serving metrics")
+
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Error("Metrics server failed")
+			stop()
+		}
+	}()
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		reconcile(cfg, repositories, reconcileRepo, store)
+
+		select {
+		case <-ctx.Done():
+			logrus.Info("Shutting down")
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), metricsShutdownPeriod)
+			defer cancel()
+
+			return server.Shutdown(shutdownCtx)
+		case <-ticker.C:
+		}
+
+		if recheck != nil {
+			repositories = recheck()
+		}
+	}
+}
+
+// reconcile processes all repositories using a pool of workers and saves the state afterwards.
+func reconcile(cfg *config.Config, repositories []string, reconcileRepo reconcileFunc, store *state.Store) {
 	repoChan := make(chan string, len(repositories))
 
 	var wg sync.WaitGroup
@@ -48,7 +283,7 @@ func Run() error {
 			defer wg.Done()
 
 			for repo := range repoChan {
-				mergerequests.ReconcileProjectMergeRequests(*cfg, repo, *gitLabClient)
+				reconcileRepo(repo)
 			}
 		}(i)
 	}
@@ -61,5 +296,53 @@ func Run() error {
 
 	wg.Wait()
 
+	if err := store.Save(); err != nil {
+		logrus.WithError(err).WithField("backend", cfg.StateBackend).Error("Failed to save state")
+	}
+
+	metrics.LastRunTimestamp.SetToCurrentTime()
+}
+
+// newStateBackend returns the configured state backend, or nil to keep the state in memory.
+func newStateBackend(cfg *config.Config, client *gl.ClientWrapper) state.Backend {
+	switch cfg.StateBackend {
+	case config.StateBackendGitLab:
+		return &state.GitLabBackend{Client: client, Project: cfg.StateGitLabProject, Key: cfg.StateGitLabVariable}
+	case config.StateBackendS3:
+		return &state.S3Backend{
+			Endpoint:        cfg.StateS3Endpoint,
+			Region:          cfg.StateS3Region,
+			Bucket:          cfg.StateS3Bucket,
+			Key:             cfg.StateS3Key,
+			AccessKeyID:     cfg.StateS3AccessKeyID,
+			SecretAccessKey: cfg.StateS3SecretAccessKey,
+		}
+	}
+
+	if cfg.StateFile == "" {
+		return nil
+	}
+
+	return &state.FileBackend{Path: cfg.StateFile}
+}
+
+// exportMetrics writes the metrics to a file and/or pushes them to a Pushgateway if configured.
+func exportMetrics(cfg *config.Config) error {
+	if cfg.MetricsFile != "" {
+		if err := metrics.WriteToFile(cfg.MetricsFile); err != nil {
+			logrus.WithError(err).WithField("path", cfg.MetricsFile).Error("Failed to write metrics file")
+
+			return err
+		}
+	}
+
+	if cfg.PushgatewayURL != "" {
+		if err := metrics.Push(cfg.PushgatewayURL); err != nil {
+			logrus.WithError(err).WithField("url", cfg.PushgatewayURL).Error("Failed to push metrics")
+
+			return err
+		}
+	}
+
 	return nil
 }
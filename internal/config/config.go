@@ -2,9 +2,14 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -13,20 +18,133 @@ type Config struct {
 	ExtractRepositoriesFromFile     bool
 	ConfigPath                      string
 	LogLevel                        logrus.Level
+	LogFormat                       string
 	GitLabAPIToken                  string
 	GitLabURL                       string
+	GitLabAuth                      string
+	GitLabAPITokenFile              string
+	GitLabJobToken                  string
+	GitLabOAuthClientID             string
+	GitLabOAuthClientSecret         string
+	GitLabOAuthRefreshToken         string
+	GitLabInstances                 []GitLabInstance
+	Preflight                       bool
+	TokenExpiryWarning              time.Duration
+	Provider                        string
+	GiteaURL                        string
+	GiteaAPIToken                   string
 	FilterByAuthorUsername          bool
 	AuthorUsername                  string
 	FilterByLabels                  bool
 	Labels                          []string
+	ExcludeLabels                   []string
+	FilterOrder                     []string
+	Policy                          string
+	OPAURL                          string
+	OPAPackage                      string
+	FilterByHoldCommand             bool
 	FilterByBranch                  bool
 	AllowedBranchRegex              string
 	AllowedBranchRegexCompiled      *regexp.Regexp
 	FilterBySucceededPipeline       bool
 	FilterByPipelineWithoutWarnings bool
+	FilterByJobs                    bool
+	RequiredJobs                    []string
+	AllowedFailureJobs              []string
+	AllowedSkippedJobs              []string
+	RetryFailedPipelines            bool
+	RetryBudget                     int
+	RetryFailureReasons             []string
+	RetryLogPatterns                []string
+	RetryLogPatternsCompiled        []*regexp.Regexp
+	TriggerMissingPipelines         bool
+	TriggerPipelineCooldown         time.Duration
+	RebaseBehindTarget              bool
+	MergeQueue                      bool
+	MergeQueueOrder                 string
+	PostMergeCheck                  bool
+	PostMergeCheckWindow            time.Duration
+	RevertLabels                    []string
+	BlockLabel                      string
+	CircuitBreaker                  bool
+	CircuitBreakerThreshold         int
+	StateBackend                    string
+	StateFile                       string
+	StateGitLabProject              string
+	StateGitLabVariable             string
+	StateS3Endpoint                 string
+	StateS3Region                   string
+	StateS3Bucket                   string
+	StateS3Key                      string
+	StateS3AccessKeyID              string
+	StateS3SecretAccessKey          string
+	FilterDraft                     bool
+	FilterConflicts                 bool
+	FilterUnresolvedDiscussions     bool
+	FilterNotMergeable              bool
+	MergeableStatuses               []string
 	AddComment                      bool
 	Comment                         string
+	CommentTemplateFile             string
+	CommentTemplate                 *template.Template
 	Approve                         string
+	ExplainRejections               bool
+	LabelDecisions                  bool
+	DecisionLabelScope              string
+	Daemon                          bool
+	Interval                        time.Duration
+	MetricsAddress                  string
+	MetricsFile                     string
+	PushgatewayURL                  string
+	Output                          string
+}
+
+// Orders in which the merge queue merges and rebases merge requests.
+const (
+	MergeQueueOrderOldest       = "oldest"
+	MergeQueueOrderUpdateType   = "update-type"
+	MergeQueueOrderSmallestDiff = "smallest-diff"
+)
+
+// GitLabInstance is an additional GitLab instance. Repositories are assigned to it
+// by prefixing them with its name, e.g. "selfhosted:group/project".
+type GitLabInstance struct {
+	Name     string
+	URL      string
+	APIToken string
+}
+
+// Ways to authenticate against the default GitLab instance.
+const (
+	GitLabAuthToken    = "token"
+	GitLabAuthJobToken = "job-token"
+	GitLabAuthOAuth    = "oauth"
+)
+
+// Code-hosting platforms renoglaab works with.
+const (
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// Formats the list and explain commands print the decision traces in.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// Backends storing the state between runs.
+const (
+	StateBackendFile   = "file"
+	StateBackendGitLab = "gitlab"
+	StateBackendS3     = "s3"
+)
+
+// defaultMergeableStatuses are the detailed merge statuses that don't prevent approving a merge request.
+// They include the statuses resolved by the approval itself or by the pipeline filter.
+var defaultMergeableStatuses = []string{
+	"mergeable", "not_approved", "approvals_syncing", "ci_must_pass", "ci_still_running",
+	"checking", "unchecked", "preparing",
 }
 
 // getDefaultConfig returns the default configuration values.
@@ -35,47 +153,168 @@ func getDefaultConfig() Config {
 		ExtractRepositoriesFromFile:     false,
 		ConfigPath:                      "$CI_PROJECT_DIR/config.js",
 		LogLevel:                        logrus.InfoLevel,
+		LogFormat:                       "text",
 		GitLabURL:                       "https://gitlab.com",
+		GitLabAuth:                      GitLabAuthToken,
+		Preflight:                       true,
+		TokenExpiryWarning:              14 * 24 * time.Hour,
+		Provider:                        ProviderGitLab,
 		FilterByAuthorUsername:          true,
 		AuthorUsername:                  "renovate-bot",
 		FilterByLabels:                  true,
 		Labels:                          []string{"renovate"},
+		FilterByHoldCommand:             true,
 		FilterByBranch:                  true,
 		AllowedBranchRegex:              `renovate/automerge`,
 		FilterBySucceededPipeline:       true,
 		FilterByPipelineWithoutWarnings: true,
+		RetryFailedPipelines:            false,
+		RetryBudget:                     2,
+		TriggerMissingPipelines:         false,
+		TriggerPipelineCooldown:         time.Hour,
+		RebaseBehindTarget:              false,
+		MergeQueue:                      false,
+		MergeQueueOrder:                 MergeQueueOrderOldest,
+		PostMergeCheck:                  false,
+		PostMergeCheckWindow:            24 * time.Hour,
+		RevertLabels:                    []string{"renoglaab-revert"},
+		BlockLabel:                      "renoglaab-blocked",
+		CircuitBreaker:                  false,
+		CircuitBreakerThreshold:         5,
+		StateBackend:                    StateBackendFile,
+		StateGitLabVariable:             "RENOGLAAB_STATE",
+		StateS3Region:                   "us-east-1",
+		StateS3Key:                      "renoglaab/state.json",
+		FilterDraft:                     true,
+		FilterConflicts:                 true,
+		FilterUnresolvedDiscussions:     true,
+		FilterNotMergeable:              true,
+		MergeableStatuses:               defaultMergeableStatuses,
+		OPAPackage:                      "renoglaab",
 		AddComment:                      true,
 		Comment:                         "Approving merge request! :ship:",
 		Approve:                         "/approve",
+		LabelDecisions:                  false,
+		DecisionLabelScope:              "renoglaab",
+		Daemon:                          false,
+		Interval:                        10 * time.Minute,
+		MetricsAddress:                  ":9090",
+		Output:                          OutputText,
 	}
 }
 
-func NewConfig() *Config {
-	cfg := getDefaultConfig() // Use default values
+// NewConfig loads the configuration from environment variables. Instead of stopping at the first problem, it
+// returns all of them joined together, along with the configuration using defaults wherever a value was invalid.
+func NewConfig() (*Config, error) {
+	return load(&env{})
+}
 
-	cfg.ExtractRepositoriesFromFile = getEnvAsBool("EXTRACT_FROM_FILE", cfg.ExtractRepositoriesFromFile)
-	cfg.ConfigPath = os.ExpandEnv(getEnv("CONFIG_PATH", cfg.ConfigPath))
-	cfg.LogLevel = mustParseLogLevel(getEnv("LOG_LEVEL", cfg.LogLevel.String()))
-	cfg.GitLabAPIToken = getEnv("GITLAB_API_TOKEN", "")
-	cfg.GitLabURL = getEnv("GITLAB_URL", cfg.GitLabURL)
-	cfg.FilterByAuthorUsername = getEnvAsBool("FILTER_BY_AUTHOR_USERNAME", cfg.FilterByAuthorUsername)
-	cfg.AuthorUsername = getEnv("AUTHOR_USERNAME", cfg.AuthorUsername)
-	cfg.FilterByLabels = getEnvAsBool("FILTER_BY_LABELS", cfg.FilterByLabels)
-	cfg.Labels = getEnvAsSlice("LABELS", strings.Join(cfg.Labels, ","))
-	cfg.FilterByBranch = getEnvAsBool("FILTER_BY_BRANCH", cfg.FilterByBranch)
-	cfg.AllowedBranchRegex = getEnv("ALLOWED_BRANCH_REGEX", cfg.AllowedBranchRegex)
-	cfg.AllowedBranchRegexCompiled = mustCompileRegex(cfg.AllowedBranchRegex)
-	cfg.FilterBySucceededPipeline = getEnvAsBool("FILTER_BY_SUCCEEDED_PIPELINE", cfg.FilterBySucceededPipeline)
-	cfg.FilterByPipelineWithoutWarnings = getEnvAsBool("FILTER_BY_PIPELINE_WITHOUT_WARNINGS", cfg.FilterByPipelineWithoutWarnings)
-	cfg.AddComment = getEnvAsBool("ADD_COMMENT", cfg.AddComment)
-	cfg.Comment = getEnv("COMMENT", cfg.Comment)
-	cfg.Approve = getEnv("APPROVE", cfg.Approve)
+// load reads the configuration, configures logging and validates the result.
+func load(e *env) (*Config, error) {
+	cfg := e.read()
 
 	configureLogging(&cfg)
 
 	cfg.PrintConfig()
 
-	return &cfg
+	return &cfg, e.validate(&cfg)
+}
+
+// read reads every setting, falling back to the defaults.
+func (e *env) read() Config {
+	cfg := getDefaultConfig() // Use default values
+
+	cfg.ExtractRepositoriesFromFile = e.getEnvAsBool("EXTRACT_FROM_FILE", cfg.ExtractRepositoriesFromFile)
+	cfg.ConfigPath = os.ExpandEnv(e.getEnv("CONFIG_PATH", cfg.ConfigPath))
+	cfg.LogLevel = e.getEnvAsLogLevel("LOG_LEVEL", cfg.LogLevel)
+	cfg.LogFormat = e.getEnvAsOneOf("LOG_FORMAT", cfg.LogFormat, "text", "json")
+	cfg.GitLabAPIToken = e.getEnv("GITLAB_API_TOKEN", "")
+	cfg.GitLabURL = e.getEnv("GITLAB_URL", cfg.GitLabURL)
+	cfg.GitLabAuth = e.getEnvAsOneOf("GITLAB_AUTH", cfg.GitLabAuth, GitLabAuthToken, GitLabAuthJobToken, GitLabAuthOAuth)
+	cfg.GitLabAPITokenFile = e.getEnv("GITLAB_API_TOKEN_FILE", cfg.GitLabAPITokenFile)
+	cfg.GitLabJobToken = e.getEnv("CI_JOB_TOKEN", "")
+	cfg.GitLabOAuthClientID = e.getEnv("GITLAB_OAUTH_CLIENT_ID", cfg.GitLabOAuthClientID)
+	cfg.GitLabOAuthClientSecret = e.getEnv("GITLAB_OAUTH_CLIENT_SECRET", "")
+	cfg.GitLabOAuthRefreshToken = e.getEnv("GITLAB_OAUTH_REFRESH_TOKEN", "")
+	cfg.GitLabInstances = e.parseGitLabInstances("GITLAB_INSTANCES")
+	cfg.Preflight = e.getEnvAsBool("PREFLIGHT", cfg.Preflight)
+	cfg.TokenExpiryWarning = e.getEnvAsDuration("TOKEN_EXPIRY_WARNING", cfg.TokenExpiryWarning)
+	cfg.Provider = e.getEnvAsOneOf("PROVIDER", cfg.Provider, ProviderGitLab, ProviderGitea)
+
+	if cfg.Provider != ProviderGitLab {
+		// Hold commands and the merge checks below rely on GitLab, so they are off unless set explicitly.
+		cfg.FilterByHoldCommand, cfg.FilterConflicts = false, false
+		cfg.FilterUnresolvedDiscussions, cfg.FilterNotMergeable = false, false
+	}
+
+	cfg.GiteaURL = e.getEnv("GITEA_URL", cfg.GiteaURL)
+	cfg.GiteaAPIToken = e.getEnv("GITEA_API_TOKEN", "")
+	cfg.FilterByAuthorUsername = e.getEnvAsBool("FILTER_BY_AUTHOR_USERNAME", cfg.FilterByAuthorUsername)
+	cfg.AuthorUsername = e.getEnv("AUTHOR_USERNAME", cfg.AuthorUsername)
+	cfg.FilterByLabels = e.getEnvAsBool("FILTER_BY_LABELS", cfg.FilterByLabels)
+	cfg.Labels = e.getEnvAsSlice("LABELS", strings.Join(cfg.Labels, ","))
+	cfg.ExcludeLabels = e.getEnvAsSlice("EXCLUDE_LABELS", strings.Join(cfg.ExcludeLabels, ","))
+	cfg.FilterOrder = e.getEnvAsSlice("FILTER_ORDER", strings.Join(cfg.FilterOrder, ","))
+	cfg.Policy = e.getEnv("POLICY", cfg.Policy)
+	cfg.OPAURL = e.getEnv("OPA_URL", cfg.OPAURL)
+	cfg.OPAPackage = e.getEnv("OPA_PACKAGE", cfg.OPAPackage)
+	cfg.FilterByHoldCommand = e.getEnvAsBool("FILTER_BY_HOLD_COMMAND", cfg.FilterByHoldCommand)
+	cfg.FilterByBranch = e.getEnvAsBool("FILTER_BY_BRANCH", cfg.FilterByBranch)
+	cfg.AllowedBranchRegex = e.getEnv("ALLOWED_BRANCH_REGEX", cfg.AllowedBranchRegex)
+	cfg.AllowedBranchRegexCompiled = e.compileRegex("ALLOWED_BRANCH_REGEX", cfg.AllowedBranchRegex)
+	cfg.FilterBySucceededPipeline = e.getEnvAsBool("FILTER_BY_SUCCEEDED_PIPELINE", cfg.FilterBySucceededPipeline)
+	cfg.FilterByPipelineWithoutWarnings = e.getEnvAsBool("FILTER_BY_PIPELINE_WITHOUT_WARNINGS", cfg.FilterByPipelineWithoutWarnings)
+	cfg.FilterByJobs = e.getEnvAsBool("FILTER_BY_JOBS", cfg.FilterByJobs)
+	cfg.RequiredJobs = e.getEnvAsSlice("REQUIRED_JOBS", strings.Join(cfg.RequiredJobs, ","))
+	cfg.AllowedFailureJobs = e.getEnvAsSlice("ALLOWED_FAILURE_JOBS", strings.Join(cfg.AllowedFailureJobs, ","))
+	cfg.AllowedSkippedJobs = e.getEnvAsSlice("ALLOWED_SKIPPED_JOBS", strings.Join(cfg.AllowedSkippedJobs, ","))
+	cfg.RetryFailedPipelines = e.getEnvAsBool("RETRY_FAILED_PIPELINES", cfg.RetryFailedPipelines)
+	cfg.RetryBudget = e.getEnvAsInt("RETRY_BUDGET", cfg.RetryBudget)
+	cfg.RetryFailureReasons = e.getEnvAsSlice("RETRY_FAILURE_REASONS", strings.Join(cfg.RetryFailureReasons, ","))
+	cfg.RetryLogPatterns = e.getEnvAsSlice("RETRY_LOG_PATTERNS", strings.Join(cfg.RetryLogPatterns, ","))
+	cfg.RetryLogPatternsCompiled = e.compileRegexes("RETRY_LOG_PATTERNS", cfg.RetryLogPatterns)
+	cfg.TriggerMissingPipelines = e.getEnvAsBool("TRIGGER_MISSING_PIPELINES", cfg.TriggerMissingPipelines)
+	cfg.TriggerPipelineCooldown = e.getEnvAsDuration("TRIGGER_PIPELINE_COOLDOWN", cfg.TriggerPipelineCooldown)
+	cfg.RebaseBehindTarget = e.getEnvAsBool("REBASE_BEHIND_TARGET", cfg.RebaseBehindTarget)
+	cfg.MergeQueue = e.getEnvAsBool("MERGE_QUEUE", cfg.MergeQueue)
+	cfg.MergeQueueOrder = e.getEnvAsOneOf("MERGE_QUEUE_ORDER", cfg.MergeQueueOrder, MergeQueueOrderOldest, MergeQueueOrderUpdateType, MergeQueueOrderSmallestDiff)
+	cfg.PostMergeCheck = e.getEnvAsBool("POST_MERGE_CHECK", cfg.PostMergeCheck)
+	cfg.PostMergeCheckWindow = e.getEnvAsDuration("POST_MERGE_CHECK_WINDOW", cfg.PostMergeCheckWindow)
+	cfg.RevertLabels = e.getEnvAsSlice("REVERT_LABELS", strings.Join(cfg.RevertLabels, ","))
+	cfg.BlockLabel = e.getEnv("BLOCK_LABEL", cfg.BlockLabel)
+	cfg.CircuitBreaker = e.getEnvAsBool("CIRCUIT_BREAKER", cfg.CircuitBreaker)
+	cfg.CircuitBreakerThreshold = e.getEnvAsInt("CIRCUIT_BREAKER_THRESHOLD", cfg.CircuitBreakerThreshold)
+	cfg.StateBackend = e.getEnvAsOneOf("STATE_BACKEND", cfg.StateBackend, StateBackendFile, StateBackendGitLab, StateBackendS3)
+	cfg.StateFile = e.getEnv("STATE_FILE", cfg.StateFile)
+	cfg.StateGitLabProject = e.getEnv("STATE_GITLAB_PROJECT", cfg.StateGitLabProject)
+	cfg.StateGitLabVariable = e.getEnv("STATE_GITLAB_VARIABLE", cfg.StateGitLabVariable)
+	cfg.StateS3Endpoint = e.getEnv("STATE_S3_ENDPOINT", cfg.StateS3Endpoint)
+	cfg.StateS3Region = e.getEnv("STATE_S3_REGION", cfg.StateS3Region)
+	cfg.StateS3Bucket = e.getEnv("STATE_S3_BUCKET", cfg.StateS3Bucket)
+	cfg.StateS3Key = e.getEnv("STATE_S3_KEY", cfg.StateS3Key)
+	cfg.StateS3AccessKeyID = e.getEnv("STATE_S3_ACCESS_KEY_ID", cfg.StateS3AccessKeyID)
+	cfg.StateS3SecretAccessKey = e.getEnv("STATE_S3_SECRET_ACCESS_KEY", cfg.StateS3SecretAccessKey)
+	cfg.FilterDraft = e.getEnvAsBool("FILTER_DRAFT", cfg.FilterDraft)
+	cfg.FilterConflicts = e.getEnvAsBool("FILTER_CONFLICTS", cfg.FilterConflicts)
+	cfg.FilterUnresolvedDiscussions = e.getEnvAsBool("FILTER_UNRESOLVED_DISCUSSIONS", cfg.FilterUnresolvedDiscussions)
+	cfg.FilterNotMergeable = e.getEnvAsBool("FILTER_NOT_MERGEABLE", cfg.FilterNotMergeable)
+	cfg.MergeableStatuses = e.getEnvAsSlice("MERGEABLE_STATUSES", strings.Join(cfg.MergeableStatuses, ","))
+	cfg.AddComment = e.getEnvAsBool("ADD_COMMENT", cfg.AddComment)
+	cfg.Comment = e.getEnv("COMMENT", cfg.Comment)
+	cfg.CommentTemplateFile = e.getEnv("COMMENT_TEMPLATE_FILE", cfg.CommentTemplateFile)
+	cfg.CommentTemplate = e.parseCommentTemplate(cfg.Comment, cfg.CommentTemplateFile)
+	cfg.Approve = e.getEnv("APPROVE", cfg.Approve)
+	cfg.ExplainRejections = e.getEnvAsBool("EXPLAIN_REJECTIONS", cfg.ExplainRejections)
+	cfg.LabelDecisions = e.getEnvAsBool("LABEL_DECISIONS", cfg.LabelDecisions)
+	cfg.DecisionLabelScope = e.getEnv("DECISION_LABEL_SCOPE", cfg.DecisionLabelScope)
+	cfg.Daemon = e.getEnvAsBool("DAEMON", cfg.Daemon)
+	cfg.Interval = e.getEnvAsDuration("INTERVAL", cfg.Interval)
+	cfg.MetricsAddress = e.getEnv("METRICS_ADDRESS", cfg.MetricsAddress)
+	cfg.MetricsFile = e.getEnv("METRICS_FILE", cfg.MetricsFile)
+	cfg.PushgatewayURL = e.getEnv("PUSHGATEWAY_URL", cfg.PushgatewayURL)
+	cfg.Output = e.getEnvAsOneOf("OUTPUT", cfg.Output, OutputText, OutputJSON)
+
+	return cfg
 }
 
 // PrintConfig logs the configuration when debug is enabled.
@@ -85,25 +324,107 @@ func (c *Config) PrintConfig() {
 			"ExtractRepositoriesFromFile":     c.ExtractRepositoriesFromFile,
 			"ConfigPath":                      c.ConfigPath,
 			"LogLevel":                        c.LogLevel.String(),
+			"LogFormat":                       c.LogFormat,
 			"GitLabURL":                       c.GitLabURL,
+			"GitLabAuth":                      c.GitLabAuth,
+			"GitLabAPITokenFile":              c.GitLabAPITokenFile,
+			"GitLabOAuthClientID":             c.GitLabOAuthClientID,
+			"GitLabInstances":                 c.gitLabInstanceURLs(),
+			"Preflight":                       c.Preflight,
+			"TokenExpiryWarning":              c.TokenExpiryWarning.String(),
+			"Provider":                        c.Provider,
+			"GiteaURL":                        c.GiteaURL,
 			"FilterByAuthorUsername":          c.FilterByAuthorUsername,
 			"AuthorUsername":                  c.AuthorUsername,
 			"FilterByLabels":                  c.FilterByLabels,
 			"Labels":                          c.Labels,
+			"ExcludeLabels":                   c.ExcludeLabels,
+			"FilterOrder":                     c.FilterOrder,
+			"Policy":                          c.Policy,
+			"OPAURL":                          c.OPAURL,
+			"OPAPackage":                      c.OPAPackage,
+			"FilterByHoldCommand":             c.FilterByHoldCommand,
 			"FilterByBranch":                  c.FilterByBranch,
 			"AllowedBranchRegex":              c.AllowedBranchRegex,
 			"AllowedBranchRegexCompiled":      c.AllowedBranchRegexCompiled,
 			"FilterBySucceededPipeline":       c.FilterBySucceededPipeline,
 			"FilterByPipelineWithoutWarnings": c.FilterByPipelineWithoutWarnings,
+			"FilterByJobs":                    c.FilterByJobs,
+			"RequiredJobs":                    c.RequiredJobs,
+			"AllowedFailureJobs":              c.AllowedFailureJobs,
+			"AllowedSkippedJobs":              c.AllowedSkippedJobs,
+			"RetryFailedPipelines":            c.RetryFailedPipelines,
+			"RetryBudget":                     c.RetryBudget,
+			"RetryFailureReasons":             c.RetryFailureReasons,
+			"RetryLogPatterns":                c.RetryLogPatterns,
+			"TriggerMissingPipelines":         c.TriggerMissingPipelines,
+			"TriggerPipelineCooldown":         c.TriggerPipelineCooldown.String(),
+			"RebaseBehindTarget":              c.RebaseBehindTarget,
+			"MergeQueue":                      c.MergeQueue,
+			"MergeQueueOrder":                 c.MergeQueueOrder,
+			"PostMergeCheck":                  c.PostMergeCheck,
+			"PostMergeCheckWindow":            c.PostMergeCheckWindow.String(),
+			"RevertLabels":                    c.RevertLabels,
+			"BlockLabel":                      c.BlockLabel,
+			"CircuitBreaker":                  c.CircuitBreaker,
+			"CircuitBreakerThreshold":         c.CircuitBreakerThreshold,
+			"StateBackend":                    c.StateBackend,
+			"StateFile":                       c.StateFile,
+			"StateGitLabProject":              c.StateGitLabProject,
+			"StateGitLabVariable":             c.StateGitLabVariable,
+			"StateS3Endpoint":                 c.StateS3Endpoint,
+			"StateS3Region":                   c.StateS3Region,
+			"StateS3Bucket":                   c.StateS3Bucket,
+			"StateS3Key":                      c.StateS3Key,
+			"FilterDraft":                     c.FilterDraft,
+			"FilterConflicts":                 c.FilterConflicts,
+			"FilterUnresolvedDiscussions":     c.FilterUnresolvedDiscussions,
+			"FilterNotMergeable":              c.FilterNotMergeable,
+			"MergeableStatuses":               c.MergeableStatuses,
 			"AddComment":                      c.AddComment,
 			"Comment":                         c.Comment,
+			"CommentTemplateFile":             c.CommentTemplateFile,
+			"ExplainRejections":               c.ExplainRejections,
+			"LabelDecisions":                  c.LabelDecisions,
+			"DecisionLabelScope":              c.DecisionLabelScope,
+			"Daemon":                          c.Daemon,
+			"Interval":                        c.Interval.String(),
+			"MetricsAddress":                  c.MetricsAddress,
+			"MetricsFile":                     c.MetricsFile,
+			"PushgatewayURL":                  c.PushgatewayURL,
+			"Output":                          c.Output,
 		}).Debug("Loaded Configuration")
 	}
 }
 
+// GitLabInstance returns the additional GitLab instance with the given name.
+func (c *Config) GitLabInstance(name string) (GitLabInstance, bool) {
+	for _, instance := range c.GitLabInstances {
+		if instance.Name == name {
+			return instance, true
+		}
+	}
+
+	return GitLabInstance{}, false
+}
+
+// TokenKey returns the environment variable holding the API token of the instance.
+func (i GitLabInstance) TokenKey() string {
+	return "GITLAB_API_TOKEN_" + strings.ToUpper(strings.ReplaceAll(i.Name, "-", "_"))
+}
+
+// gitLabInstanceURLs maps the additional instances to their URLs, leaving out the tokens.
+func (c *Config) gitLabInstanceURLs() map[string]string {
+	urls := make(map[string]string, len(c.GitLabInstances))
+	for _, instance := range c.GitLabInstances {
+		urls[instance.Name] = instance.URL
+	}
+
+	return urls
+}
+
 func configureLogging(cfg *Config) {
-	logFormat := getEnv("LOG_FORMAT", "text")
-	if logFormat == "json" {
+	if cfg.LogFormat == "json" {
 		logrus.SetFormatter(&logrus.JSONFormatter{})
 	} else {
 		logrus.SetFormatter(&logrus.TextFormatter{})
@@ -112,7 +433,34 @@ func configureLogging(cfg *Config) {
 	logrus.SetLevel(cfg.LogLevel)
 }
 
-func getEnv(key, defaultValue string) string {
+// env reads settings from command line flags and environment variables. It remembers the keys it read
+// and every value it couldn't use, so all problems can be reported at once.
+type env struct {
+	// flags holds the values set on the command line, keyed like the environment variables they override.
+	flags map[string]string
+	// dry ignores any value, to find out which settings exist.
+	dry      bool
+	keys     []string
+	bools    []string
+	problems []error
+}
+
+// problem records an invalid setting.
+func (e *env) problem(key string, err error) {
+	e.problems = append(e.problems, fmt.Errorf("%s: %w", key, err))
+}
+
+func (e *env) getEnv(key, defaultValue string) string {
+	e.keys = append(e.keys, key)
+
+	if e.dry {
+		return defaultValue
+	}
+
+	if value, exists := e.flags[key]; exists {
+		return value
+	}
+
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
@@ -120,21 +468,60 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getEnvAsBool(key string, defaultValue bool) bool {
-	if value, exists := os.LookupEnv(key); exists {
-		switch strings.ToLower(value) {
-		case "true", "1", "yes", "on":
-			return true
-		case "false", "0", "no", "off":
-			return false
-		}
+func (e *env) getEnvAsBool(key string, defaultValue bool) bool {
+	e.bools = append(e.bools, key)
+
+	value := e.getEnv(key, "")
+	if value == "" {
+		return defaultValue
 	}
 
+	switch strings.ToLower(value) {
+	case "true", "1", "yes", "on":
+		return true
+	case "false", "0", "no", "off":
+		return false
+	}
+
+	e.problem(key, fmt.Errorf("%w %q, use true or false", errInvalidBool, value))
+
 	return defaultValue
 }
 
-func getEnvAsSlice(key, defaultValue string) []string {
-	valueStr := getEnv(key, defaultValue)
+func (e *env) getEnvAsInt(key string, defaultValue int) int {
+	value := e.getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		e.problem(key, fmt.Errorf("%w %q", errInvalidNumber, value))
+
+		return defaultValue
+	}
+
+	return number
+}
+
+func (e *env) getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	value := e.getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		e.problem(key, fmt.Errorf("%w %q, use e.g. 90s, 10m or 24h", errInvalidDuration, value))
+
+		return defaultValue
+	}
+
+	return duration
+}
+
+func (e *env) getEnvAsSlice(key, defaultValue string) []string {
+	valueStr := e.getEnv(key, defaultValue)
 	if valueStr == "" {
 		return nil
 	}
@@ -142,7 +529,36 @@ func getEnvAsSlice(key, defaultValue string) []string {
 	return strings.Split(valueStr, ",")
 }
 
-func mustCompileRegex(pattern string) *regexp.Regexp {
+// getEnvAsOneOf returns the value if it is one of the options.
+func (e *env) getEnvAsOneOf(key, defaultValue string, options ...string) string {
+	value := e.getEnv(key, defaultValue)
+	if slices.Contains(options, value) {
+		return value
+	}
+
+	e.problem(key, fmt.Errorf("%w %q, use one of %s", errInvalidOption, value, strings.Join(options, ", ")))
+
+	return defaultValue
+}
+
+func (e *env) getEnvAsLogLevel(key string, defaultValue logrus.Level) logrus.Level {
+	value := e.getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	logLevel, err := logrus.ParseLevel(value)
+	if err != nil {
+		e.problem(key, fmt.Errorf("%w %q, use debug, info, warn or error", errInvalidOption, value))
+
+		return defaultValue
+	}
+
+	return logLevel
+}
+
+// compileRegex compiles a pattern that has to match the whole value.
+func (e *env) compileRegex(key, pattern string) *regexp.Regexp {
 	if !strings.HasPrefix(pattern, "^") {
 		pattern = "^" + pattern
 	}
@@ -153,17 +569,78 @@ func mustCompileRegex(pattern string) *regexp.Regexp {
 
 	re, err := regexp.Compile(pattern)
 	if err != nil {
-		logrus.Fatalf("Invalid regex pattern: %v", err)
+		e.problem(key, err)
+
+		return regexp.MustCompile("^$")
 	}
 
 	return re
 }
 
-func mustParseLogLevel(levelStr string) logrus.Level {
-	logLevel, err := logrus.ParseLevel(levelStr)
+// compileRegexes compiles unanchored patterns, e.g. to search logs.
+func (e *env) compileRegexes(key string, patterns []string) []*regexp.Regexp {
+	regexes := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			e.problem(key, err)
+
+			continue
+		}
+
+		regexes = append(regexes, re)
+	}
+
+	return regexes
+}
+
+// parseCommentTemplate parses the comment as a Go template.
+// If a template file is given, its content takes precedence over the comment.
+func (e *env) parseCommentTemplate(comment, path string) *template.Template {
+	key := "COMMENT"
+
+	if path != "" {
+		key = "COMMENT_TEMPLATE_FILE"
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			e.problem(key, err)
+
+			return nil
+		}
+
+		comment = string(content)
+	}
+
+	tmpl, err := template.New("comment").Funcs(template.FuncMap{"join": strings.Join}).Parse(comment)
 	if err != nil {
-		logLevel = logrus.InfoLevel
+		e.problem(key, err)
+
+		return nil
 	}
 
-	return logLevel
+	return tmpl
+}
+
+// parseGitLabInstances parses "name=url" entries. The token of an instance is read from
+// GITLAB_API_TOKEN_<NAME>, with the name upper-cased and dashes replaced by underscores.
+func (e *env) parseGitLabInstances(key string) []GitLabInstance {
+	entries := e.getEnvAsSlice(key, "")
+	instances := make([]GitLabInstance, 0, len(entries))
+
+	for _, entry := range entries {
+		name, url, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || name == "" || url == "" || strings.ContainsAny(name, ":/") {
+			e.problem(key, fmt.Errorf("%w %q, use name=url", errInvalidOption, entry))
+
+			continue
+		}
+
+		instance := GitLabInstance{Name: name, URL: url}
+		instance.APIToken = e.getEnv(instance.TokenKey(), "")
+		instances = append(instances, instance)
+	}
+
+	return instances
 }
@@ -6,9 +6,11 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const defaultBranchRegex = `^renovate/automerge$`
@@ -21,7 +23,8 @@ func TestNewConfig(t *testing.T) {
 	t.Setenv("GITLAB_URL", "https://test.gitlab.com")
 	t.Setenv("LOG_LEVEL", "warn")
 
-	config := NewConfig()
+	config, err := NewConfig()
+	require.NoError(t, err)
 
 	assert.Equal(t, "config.js", config.ConfigPath, "Config does not match")
 	assert.Equal(t, []string{"renovate"}, config.Labels, "Labels does not match")
@@ -35,62 +38,108 @@ func TestNewConfig(t *testing.T) {
 func TestNewConfigWithInvalidLogLevel(t *testing.T) {
 	t.Setenv("LOG_LEVEL", "invalid")
 
-	config := NewConfig()
+	config, err := NewConfig()
 
+	require.ErrorIs(t, err, errInvalidOption)
+	assert.ErrorContains(t, err, `LOG_LEVEL: invalid value "invalid"`)
 	assert.Equal(t, logrus.InfoLevel, config.LogLevel, "LogLevel should default to InfoLevel when an invalid log level is provided")
 }
 
 func TestGetEnv(t *testing.T) {
+	e := &env{}
+
 	key := "TEST_ENV_VAR"
 	defaultValue := "default_value"
 
-	value := getEnv(key, defaultValue)
+	value := e.getEnv(key, defaultValue)
 	assert.Equal(t, defaultValue, value, "Expected default value when environment variable is not set")
 
 	expectedValue := "set_value"
 	t.Setenv(key, expectedValue)
-	value = getEnv(key, defaultValue)
+	value = e.getEnv(key, defaultValue)
 	assert.Equal(t, expectedValue, value, "Expected environment variable value when it is set")
 }
 
 func TestGetEnvAsSlice(t *testing.T) {
+	e := &env{}
+
 	key := "TEST_ENV_SLICE"
 	defaultValue := "default1,default2"
 
-	value := getEnvAsSlice(key, defaultValue)
+	value := e.getEnvAsSlice(key, defaultValue)
 	expected := strings.Split(defaultValue, ",")
 	assert.Equal(t, expected, value, "Expected default value slice when environment variable is not set")
 
 	expectedValue := "value1,value2,value3"
 	t.Setenv(key, expectedValue)
-	value = getEnvAsSlice(key, defaultValue)
+	value = e.getEnvAsSlice(key, defaultValue)
 	expected = strings.Split(expectedValue, ",")
 	assert.Equal(t, expected, value, "Expected environment variable value slice when it is set")
 }
 
 func TestGetEnvAsBool(t *testing.T) {
+	e := &env{}
+
 	key := "TEST_ENV_BOOL"
 	defaultValue := false
 
-	value := getEnvAsBool(key, defaultValue)
+	value := e.getEnvAsBool(key, defaultValue)
 	assert.Equal(t, defaultValue, value, "Expected default value when environment variable is not set")
 
 	t.Setenv(key, "true")
-	value = getEnvAsBool(key, defaultValue)
+	value = e.getEnvAsBool(key, defaultValue)
 	assert.True(t, value, "Expected true when environment variable is set to 'true'")
 
 	t.Setenv(key, "false")
-	value = getEnvAsBool(key, defaultValue)
+	value = e.getEnvAsBool(key, defaultValue)
 	assert.False(t, value, "Expected false when environment variable is set to 'false'")
 
 	t.Setenv(key, "some_random_value")
-	value = getEnvAsBool(key, defaultValue)
+	value = e.getEnvAsBool(key, defaultValue)
 	assert.False(t, value, "Expected false when environment variable is set to an arbitrary string")
+	require.Len(t, e.problems, 1, "Expected the arbitrary string to be reported")
+	assert.ErrorIs(t, e.problems[0], errInvalidBool)
+}
+
+func TestGetEnvAsDuration(t *testing.T) {
+	e := &env{}
+
+	key := "TEST_ENV_DURATION"
+	defaultValue := 10 * time.Minute
+
+	value := e.getEnvAsDuration(key, defaultValue)
+	assert.Equal(t, defaultValue, value, "Expected default value when environment variable is not set")
+
+	t.Setenv(key, "30s")
+	value = e.getEnvAsDuration(key, defaultValue)
+	assert.Equal(t, 30*time.Second, value, "Expected parsed duration when environment variable is set")
+
+	t.Setenv(key, "soon")
+	value = e.getEnvAsDuration(key, defaultValue)
+	assert.Equal(t, defaultValue, value, "Expected default value when environment variable is not a duration")
+	assert.Len(t, e.problems, 1, "Expected the invalid duration to be reported")
+}
+
+func TestGetEnvAsInt(t *testing.T) {
+	e := &env{}
+
+	key := "TEST_ENV_INT"
+
+	assert.Equal(t, 2, e.getEnvAsInt(key, 2), "Expected default value when environment variable is not set")
+
+	t.Setenv(key, "5")
+	assert.Equal(t, 5, e.getEnvAsInt(key, 2), "Expected parsed number when environment variable is set")
+
+	t.Setenv(key, "many")
+	assert.Equal(t, 2, e.getEnvAsInt(key, 2), "Expected default value when environment variable is not a number")
+	assert.Len(t, e.problems, 1, "Expected the invalid number to be reported")
 }
+
 func TestPrintConfig(t *testing.T) {
 	t.Setenv("LOG_LEVEL", "debug")
 	t.Setenv("CONFIG_PATH", "config.js")
 	t.Setenv("GITLAB_URL", "https://test.gitlab.com")
+	t.Setenv("GITLAB_API_TOKEN", "token")
 	t.Setenv("LABELS", "renovate")
 	t.Setenv("FILTER_BY_BRANCH", "true")
 	t.Setenv("FILTER_BY_SUCCEEDED_PIPELINE", "true")
@@ -101,7 +150,8 @@ func TestPrintConfig(t *testing.T) {
 	t.Setenv("ADD_COMMENT", "true")
 	t.Setenv("APPROVE_COMMENT", "Approving merge request! :ship:")
 
-	config := NewConfig()
+	config, err := NewConfig()
+	require.NoError(t, err)
 
 	// Capture the log output
 	var logOutput strings.Builder
@@ -124,3 +174,46 @@ func TestPrintConfig(t *testing.T) {
 	assert.Contains(t, logContent, "AuthorUsername=renovate-bot", "Expected AuthorUsername in log output")
 	assert.Contains(t, logContent, "AddComment=true", "Expected AddComment in log output")
 }
+
+func TestParseCommentTemplate(t *testing.T) {
+	e := &env{}
+
+	tmpl := e.parseCommentTemplate("Approving {{ .Title }}", "")
+
+	var out strings.Builder
+	assert.NoError(t, tmpl.Execute(&out, map[string]string{"Title": "MR"}))
+	assert.Equal(t, "Approving MR", out.String())
+
+	path := t.TempDir() + "/comment.tmpl"
+	assert.NoError(t, os.WriteFile(path, []byte(`{{ join .Names ", " }}`), 0o600))
+
+	tmpl = e.parseCommentTemplate("ignored", path)
+
+	out.Reset()
+	assert.NoError(t, tmpl.Execute(&out, map[string][]string{"Names": {"a", "b"}}))
+	assert.Equal(t, "a, b", out.String())
+}
+
+func TestParseGitLabInstances(t *testing.T) {
+	t.Setenv("GITLAB_INSTANCES", "self-hosted=https://gitlab.example.com, other=https://gitlab.other.com,broken")
+	t.Setenv("GITLAB_API_TOKEN_SELF_HOSTED", "glpat-selfhosted")
+
+	e := &env{}
+	instances := e.parseGitLabInstances("GITLAB_INSTANCES")
+	require.Len(t, e.problems, 1)
+	assert.ErrorContains(t, e.problems[0], `GITLAB_INSTANCES: invalid value "broken"`)
+
+	assert.Equal(t, []GitLabInstance{
+		{Name: "self-hosted", URL: "https://gitlab.example.com", APIToken: "glpat-selfhosted"},
+		{Name: "other", URL: "https://gitlab.other.com"},
+	}, instances)
+
+	config := Config{GitLabInstances: instances}
+
+	instance, ok := config.GitLabInstance("other")
+	assert.True(t, ok)
+	assert.Equal(t, "https://gitlab.other.com", instance.URL)
+
+	_, ok = config.GitLabInstance("missing")
+	assert.False(t, ok)
+}
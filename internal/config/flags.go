@@ -0,0 +1,76 @@
+package config
+
+import (
+	"flag"
+	"slices"
+	"strings"
+)
+
+// secretKeys are only read from the environment, as the command line is visible to other users of the machine.
+var secretKeys = []string{
+	"GITLAB_API_TOKEN",
+	"GITLAB_OAUTH_CLIENT_SECRET",
+	"GITLAB_OAUTH_REFRESH_TOKEN",
+	"CI_JOB_TOKEN",
+	"GITEA_API_TOKEN",
+	"STATE_S3_ACCESS_KEY_ID",
+	"STATE_S3_SECRET_ACCESS_KEY",
+}
+
+// Flags defines a command line flag on fs for every setting, named after its environment variable,
+// e.g. --filter-by-branch for FILTER_BY_BRANCH. It returns the values set once fs is parsed,
+// to be passed to NewConfigWithFlags. Secrets have no flag.
+func Flags(fs *flag.FlagSet) map[string]string {
+	values := map[string]string{}
+
+	// Reading the configuration without any value finds every setting and which ones are booleans.
+	settings := &env{dry: true}
+	settings.read()
+
+	for _, key := range settings.keys {
+		name := FlagName(key)
+		if slices.Contains(secretKeys, key) || fs.Lookup(name) != nil {
+			continue
+		}
+
+		fs.Var(&flagValue{key: key, values: values, bool: slices.Contains(settings.bools, key)}, name, "Overrides $"+key)
+	}
+
+	return values
+}
+
+// FlagName returns the name of the flag setting the environment variable key.
+func FlagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// NewConfigWithFlags loads the configuration like NewConfig, with the flags taking precedence over the environment.
+func NewConfigWithFlags(flags map[string]string) (*Config, error) {
+	return load(&env{flags: flags})
+}
+
+// flagValue stores the value of a flag, to be validated along with the environment variables.
+type flagValue struct {
+	key    string
+	values map[string]string
+	bool   bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil || v.values == nil {
+		return ""
+	}
+
+	return v.values[v.key]
+}
+
+func (v *flagValue) Set(value string) error {
+	v.values[v.key] = value
+
+	return nil
+}
+
+// IsBoolFlag allows boolean settings to be enabled without a value, e.g. --daemon.
+func (v *flagValue) IsBoolFlag() bool {
+	return v.bool
+}
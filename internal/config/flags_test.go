@@ -0,0 +1,57 @@
+//nolint:lll
+package config
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlags(t *testing.T) {
+	t.Setenv("LABELS", "from-env")
+	t.Setenv("INTERVAL", "1h")
+	t.Setenv("GITLAB_API_TOKEN", "token")
+
+	fs := flag.NewFlagSet("renoglaab", flag.ContinueOnError)
+	values := Flags(fs)
+
+	for _, name := range []string{"filter-by-branch", "gitlab-url", "gitlab-api-token-file", "log-format", "state-s3-bucket"} {
+		assert.NotNil(t, fs.Lookup(name), name)
+	}
+
+	for _, name := range []string{"gitlab-api-token", "ci-job-token", "gitea-api-token", "gitlab-oauth-client-secret", "state-s3-secret-access-key"} {
+		assert.Nil(t, fs.Lookup(name), name)
+	}
+
+	require.NoError(t, fs.Parse([]string{"--daemon", "--filter-by-branch=false", "--labels", "from-flag", "group/project", "42"}))
+	assert.Equal(t, []string{"group/project", "42"}, fs.Args())
+
+	cfg, err := NewConfigWithFlags(values)
+	require.NoError(t, err)
+
+	assert.True(t, cfg.Daemon)
+	assert.False(t, cfg.FilterByBranch)
+	assert.Equal(t, []string{"from-flag"}, cfg.Labels)
+	assert.Equal(t, time.Hour, cfg.Interval)
+}
+
+func TestFlagsInvalidValue(t *testing.T) {
+	fs := flag.NewFlagSet("renoglaab", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	values := Flags(fs)
+
+	require.Error(t, fs.Parse([]string{"--filter-by-lables"}))
+	require.NoError(t, fs.Parse([]string{"--interval", "soon"}))
+
+	_, err := NewConfigWithFlags(values)
+	require.ErrorIs(t, err, errInvalidDuration)
+	assert.ErrorContains(t, err, `INTERVAL: invalid duration "soon"`)
+}
+
+func TestFlagName(t *testing.T) {
+	assert.Equal(t, "filter-by-pipeline-without-warnings", FlagName("FILTER_BY_PIPELINE_WITHOUT_WARNINGS"))
+}
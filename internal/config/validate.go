@@ -0,0 +1,240 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownSetting reports an environment variable that looks like a misspelled setting.
+// As the environment is shared with everything else it may be a false alarm, so only validate must stop on it.
+var ErrUnknownSetting = errors.New("unknown setting")
+
+// Problems found while loading the configuration.
+var (
+	errInvalidBool     = errors.New("invalid boolean")
+	errInvalidNumber   = errors.New("invalid number")
+	errInvalidDuration = errors.New("invalid duration")
+	errInvalidOption   = errors.New("invalid value")
+	errConflict        = errors.New("conflicting settings")
+	errMissing         = errors.New("missing setting")
+)
+
+// externalKeys are environment variables read outside this package.
+var externalKeys = []string{"RENOVATE_EXTRA_FLAGS", "CI_PROJECT_DIR"}
+
+// gitLabOnly lists the settings enabling features the other providers don't support.
+func gitLabOnly(cfg *Config) map[string]bool {
+	return map[string]bool{
+		"FILTER_BY_HOLD_COMMAND":        cfg.FilterByHoldCommand,
+		"FILTER_CONFLICTS":              cfg.FilterConflicts,
+		"FILTER_UNRESOLVED_DISCUSSIONS": cfg.FilterUnresolvedDiscussions,
+		"FILTER_NOT_MERGEABLE":          cfg.FilterNotMergeable,
+		"FILTER_BY_JOBS":                cfg.FilterByJobs,
+		"RETRY_FAILED_PIPELINES":        cfg.RetryFailedPipelines,
+		"TRIGGER_MISSING_PIPELINES":     cfg.TriggerMissingPipelines,
+		"REBASE_BEHIND_TARGET":          cfg.RebaseBehindTarget,
+		"MERGE_QUEUE":                   cfg.MergeQueue,
+		"POST_MERGE_CHECK":              cfg.PostMergeCheck,
+		"CIRCUIT_BREAKER":               cfg.CircuitBreaker,
+		"EXPLAIN_REJECTIONS":            cfg.ExplainRejections,
+		"LABEL_DECISIONS":               cfg.LabelDecisions,
+		"POLICY":                        cfg.Policy != "",
+		"OPA_URL":                       cfg.OPAURL != "",
+	}
+}
+
+// validate returns every problem found while reading the settings, misspelled settings and conflicting options.
+func (e *env) validate(cfg *Config) error {
+	problems := e.problems
+
+	problems = append(problems, e.unknownSettings()...)
+	problems = append(problems, conflicts(cfg)...)
+
+	return errors.Join(problems...)
+}
+
+// unknownSettings reports environment variables that look like a misspelled setting.
+// Any other unknown variable is ignored, as the environment is shared with everything else.
+func (e *env) unknownSettings() []error {
+	known := slices.Concat(e.keys, externalKeys)
+
+	var problems []error
+
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+
+		// Variables predefined by GitLab CI, e.g. CI_PROJECT_ID, are never settings.
+		if slices.Contains(known, key) || strings.HasPrefix(key, "CI_") {
+			continue
+		}
+
+		if suggestion, ok := nearMiss(key, known); ok {
+			problems = append(problems, fmt.Errorf("%s: %w, did you mean %s?", key, ErrUnknownSetting, suggestion))
+		}
+	}
+
+	slices.SortFunc(problems, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+
+	return problems
+}
+
+// nearMiss returns the known key closest to key, if it is only a typo away.
+func nearMiss(key string, known []string) (string, bool) {
+	best, bestDistance := "", -1
+
+	for _, candidate := range known {
+		// Short keys only tolerate a single typo, or every short variable would look like a setting.
+		maxDistance := 2
+		if len(candidate) <= 6 {
+			maxDistance = 1
+		}
+
+		distance := levenshtein(strings.ToUpper(key), candidate)
+		if distance <= maxDistance && (bestDistance < 0 || distance < bestDistance) {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	return best, bestDistance >= 0
+}
+
+// levenshtein returns the number of single character edits needed to turn a into b.
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// conflicts reports settings that can't be used together or miss a setting they depend on.
+func conflicts(cfg *Config) []error {
+	var problems []error
+
+	conflict := func(key, format string, args ...any) {
+		problems = append(problems, fmt.Errorf("%s: %w: %s", key, errConflict, fmt.Sprintf(format, args...)))
+	}
+
+	missing := func(key, reason string) {
+		problems = append(problems, fmt.Errorf("%s: %w, %s", key, errMissing, reason))
+	}
+
+	if cfg.Provider != ProviderGitLab {
+		enabled := gitLabOnly(cfg)
+		for _, key := range slices.Sorted(maps.Keys(enabled)) {
+			if enabled[key] {
+				conflict(key, "only supported with PROVIDER=%s", ProviderGitLab)
+			}
+		}
+
+		if cfg.StateBackend == StateBackendGitLab {
+			conflict("STATE_BACKEND", "%s needs PROVIDER=%s", StateBackendGitLab, ProviderGitLab)
+		}
+
+		if cfg.GiteaURL == "" {
+			missing("GITEA_URL", "needed for PROVIDER="+cfg.Provider)
+		}
+	}
+
+	// With GITLAB_INSTANCES the default instance may be unused, its token is then only checked once the repositories are known.
+	if cfg.Provider == ProviderGitLab && len(cfg.GitLabInstances) == 0 {
+		switch {
+		case cfg.GitLabAuth == GitLabAuthJobToken:
+			if cfg.GitLabJobToken == "" {
+				missing("CI_JOB_TOKEN", "needed for GITLAB_AUTH="+GitLabAuthJobToken)
+			}
+		case cfg.GitLabAPIToken != "" || cfg.GitLabAPITokenFile != "":
+			// Token and OAuth authentication both take the token given either way.
+		case cfg.GitLabAuth == GitLabAuthOAuth:
+			if cfg.GitLabOAuthRefreshToken == "" {
+				missing("GITLAB_API_TOKEN", "set it, GITLAB_API_TOKEN_FILE or GITLAB_OAUTH_REFRESH_TOKEN")
+			}
+		default:
+			missing("GITLAB_API_TOKEN", "set it or GITLAB_API_TOKEN_FILE")
+		}
+	}
+
+	if cfg.GitLabAuth == GitLabAuthJobToken && cfg.GitLabAPITokenFile != "" {
+		conflict("GITLAB_API_TOKEN_FILE", "not used with GITLAB_AUTH=%s", GitLabAuthJobToken)
+	}
+
+	if cfg.GitLabOAuthRefreshToken != "" && cfg.GitLabAuth != GitLabAuthOAuth {
+		conflict("GITLAB_OAUTH_REFRESH_TOKEN", "only used with GITLAB_AUTH=%s", GitLabAuthOAuth)
+	}
+
+	for _, instance := range cfg.GitLabInstances {
+		if instance.APIToken == "" {
+			missing(instance.TokenKey(), "needed for GitLab instance "+instance.Name)
+		}
+	}
+
+	if cfg.FilterByAuthorUsername && cfg.AuthorUsername == "" {
+		missing("AUTHOR_USERNAME", "needed for FILTER_BY_AUTHOR_USERNAME")
+	}
+
+	if cfg.FilterByLabels && len(cfg.Labels) == 0 {
+		missing("LABELS", "needed for FILTER_BY_LABELS")
+	}
+
+	if cfg.OPAURL != "" {
+		if u, err := url.Parse(cfg.OPAURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			conflict("OPA_URL", "must be an http or https URL, e.g. http://localhost:8181")
+		}
+
+		if cfg.OPAPackage == "" {
+			missing("OPA_PACKAGE", "needed for OPA_URL")
+		}
+	}
+
+	if cfg.RetryBudget < 0 {
+		conflict("RETRY_BUDGET", "must not be negative")
+	}
+
+	if cfg.CircuitBreaker && cfg.CircuitBreakerThreshold < 1 {
+		conflict("CIRCUIT_BREAKER_THRESHOLD", "must be at least 1 with CIRCUIT_BREAKER")
+	}
+
+	if cfg.Daemon && cfg.Interval <= 0 {
+		conflict("INTERVAL", "must be positive with DAEMON")
+	}
+
+	switch cfg.StateBackend {
+	case StateBackendGitLab:
+		if cfg.StateGitLabProject == "" {
+			missing("STATE_GITLAB_PROJECT", "needed for STATE_BACKEND="+StateBackendGitLab)
+		}
+	case StateBackendS3:
+		if cfg.StateS3Endpoint == "" {
+			missing("STATE_S3_ENDPOINT", "needed for STATE_BACKEND="+StateBackendS3)
+		}
+
+		if cfg.StateS3Bucket == "" {
+			missing("STATE_S3_BUCKET", "needed for STATE_BACKEND="+StateBackendS3)
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,157 @@
+//nolint:lll
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigCollectsProblems(t *testing.T) {
+	t.Setenv("FILTER_BY_BRANCH", "maybe")
+	t.Setenv("ALLOWED_BRANCH_REGEX", "renovate/(")
+	t.Setenv("FILTER_BY_LABEL", "true")
+	t.Setenv("PROVIDER", "gitea")
+	t.Setenv("MERGE_QUEUE", "true")
+	t.Setenv("STATE_BACKEND", "s3")
+
+	_, err := NewConfig()
+	require.Error(t, err)
+
+	assert.ErrorIs(t, err, errInvalidBool)
+	assert.ErrorIs(t, err, ErrUnknownSetting)
+	assert.ErrorIs(t, err, errConflict)
+	assert.ErrorIs(t, err, errMissing)
+	assert.ErrorContains(t, err, `FILTER_BY_BRANCH: invalid boolean "maybe", use true or false`)
+	assert.ErrorContains(t, err, "ALLOWED_BRANCH_REGEX: error parsing regexp")
+	assert.ErrorContains(t, err, "FILTER_BY_LABEL: unknown setting, did you mean FILTER_BY_LABELS?")
+	assert.ErrorContains(t, err, "MERGE_QUEUE: conflicting settings: only supported with PROVIDER=gitlab")
+	assert.ErrorContains(t, err, "GITEA_URL: missing setting, needed for PROVIDER=gitea")
+	assert.ErrorContains(t, err, "STATE_S3_ENDPOINT: missing setting")
+	assert.ErrorContains(t, err, "STATE_S3_BUCKET: missing setting")
+}
+
+func TestNewConfigGiteaDefaults(t *testing.T) {
+	t.Setenv("PROVIDER", "gitea")
+	t.Setenv("GITEA_URL", "https://gitea.example.com")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.False(t, cfg.FilterByHoldCommand)
+	assert.False(t, cfg.FilterNotMergeable)
+	assert.True(t, cfg.FilterDraft)
+
+	t.Setenv("FILTER_CONFLICTS", "true")
+
+	_, err = NewConfig()
+	assert.ErrorContains(t, err, "FILTER_CONFLICTS: conflicting settings: only supported with PROVIDER=gitlab")
+}
+
+func TestNewConfigGitLabToken(t *testing.T) {
+	_, err := NewConfig()
+	assert.ErrorContains(t, err, "GITLAB_API_TOKEN: missing setting, set it or GITLAB_API_TOKEN_FILE")
+
+	t.Setenv("GITLAB_AUTH", "oauth")
+
+	_, err = NewConfig()
+	assert.ErrorContains(t, err, "GITLAB_API_TOKEN: missing setting, set it, GITLAB_API_TOKEN_FILE or GITLAB_OAUTH_REFRESH_TOKEN")
+
+	t.Setenv("GITLAB_OAUTH_REFRESH_TOKEN", "refresh")
+
+	_, err = NewConfig()
+	require.NoError(t, err)
+
+	t.Setenv("GITLAB_AUTH", "token")
+	t.Setenv("GITLAB_OAUTH_REFRESH_TOKEN", "")
+	t.Setenv("GITLAB_API_TOKEN_FILE", "/run/secrets/gitlab-token")
+
+	_, err = NewConfig()
+	assert.NoError(t, err)
+}
+
+func TestNewConfigInstanceToken(t *testing.T) {
+	t.Setenv("GITLAB_INSTANCES", "selfhosted=https://gitlab.example.com")
+
+	_, err := NewConfig()
+	assert.ErrorContains(t, err, "GITLAB_API_TOKEN_SELFHOSTED: missing setting, needed for GitLab instance selfhosted")
+
+	t.Setenv("GITLAB_API_TOKEN_SELFHOSTED", "token")
+
+	_, err = NewConfig()
+	assert.NoError(t, err)
+}
+
+func TestNewConfigOPA(t *testing.T) {
+	t.Setenv("GITLAB_API_TOKEN", "token")
+	t.Setenv("OPA_URL", "localhost:8181")
+	t.Setenv("OPA_PACKAGE", "")
+
+	_, err := NewConfig()
+	assert.ErrorContains(t, err, "OPA_URL: conflicting settings: must be an http or https URL")
+	assert.ErrorContains(t, err, "OPA_PACKAGE: missing setting, needed for OPA_URL")
+
+	t.Setenv("OPA_URL", "http://localhost:8181")
+	t.Setenv("OPA_PACKAGE", "renovate.approval")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "renovate.approval", cfg.OPAPackage)
+}
+
+func TestNearMiss(t *testing.T) {
+	known := []string{"LABELS", "FILTER_BY_LABELS", "EXCLUDE_LABELS", "CI_PROJECT_DIR"}
+
+	tests := []struct {
+		key        string
+		suggestion string
+	}{
+		{key: "FILTER_BY_LABEL", suggestion: "FILTER_BY_LABELS"},
+		{key: "filter_by_labels", suggestion: "FILTER_BY_LABELS"},
+		{key: "EXCLUDED_LABELS", suggestion: "EXCLUDE_LABELS"},
+		{key: "LABEL", suggestion: "LABELS"},
+		{key: "LANG"},
+		{key: "HOME"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			suggestion, ok := nearMiss(tt.key, known)
+			assert.Equal(t, tt.suggestion != "", ok)
+			assert.Equal(t, tt.suggestion, suggestion)
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("LABELS", "LABELS"))
+	assert.Equal(t, 1, levenshtein("LABEL", "LABELS"))
+	assert.Equal(t, 2, levenshtein("GITEA", "GITLAB"))
+	assert.Equal(t, 6, levenshtein("", "LABELS"))
+}
+
+func TestConflicts(t *testing.T) {
+	cfg := getDefaultConfig()
+	cfg.GitLabAPIToken = "token"
+	assert.Empty(t, conflicts(&cfg))
+
+	cfg.GitLabAuth = GitLabAuthJobToken
+	cfg.GitLabAPITokenFile = "/run/secrets/token"
+	cfg.GitLabOAuthRefreshToken = "refresh"
+	cfg.CircuitBreaker = true
+	cfg.CircuitBreakerThreshold = 0
+	cfg.StateBackend = StateBackendGitLab
+
+	var messages []string
+	for _, problem := range conflicts(&cfg) {
+		messages = append(messages, problem.Error())
+	}
+
+	assert.Equal(t, []string{
+		"CI_JOB_TOKEN: missing setting, needed for GITLAB_AUTH=job-token",
+		"GITLAB_API_TOKEN_FILE: conflicting settings: not used with GITLAB_AUTH=job-token",
+		"GITLAB_OAUTH_REFRESH_TOKEN: conflicting settings: only used with GITLAB_AUTH=oauth",
+		"CIRCUIT_BREAKER_THRESHOLD: conflicting settings: must be at least 1 with CIRCUIT_BREAKER",
+		"STATE_GITLAB_PROJECT: missing setting, needed for STATE_BACKEND=gitlab",
+	}, messages)
+}
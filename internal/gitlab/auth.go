@@ -0,0 +1,136 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrMissingToken = errors.New("no token configured")
+	errEmptyToken   = errors.New("token file is empty")
+)
+
+// NewAuthSource returns the authentication for the default instance configured by GITLAB_AUTH.
+//
+// Personal, project and group access tokens all use "token". They are read from GITLAB_API_TOKEN_FILE
+// if set, which is re-read whenever it changes, e.g. after a Vault agent or Kubernetes rotated the secret.
+// OAuth2 access tokens are either given the same way or refreshed with GITLAB_OAUTH_REFRESH_TOKEN.
+func NewAuthSource(cfg *config.Config) (gitlab.AuthSource, error) {
+	switch cfg.GitLabAuth {
+	case config.GitLabAuthJobToken:
+		if cfg.GitLabJobToken == "" {
+			return nil, fmt.Errorf("%w: CI_JOB_TOKEN must be set", ErrMissingToken)
+		}
+
+		return gitlab.JobTokenAuthSource{Token: cfg.GitLabJobToken}, nil
+	case config.GitLabAuthOAuth:
+		if cfg.GitLabOAuthRefreshToken != "" {
+			return newOAuthRefreshSource(cfg), nil
+		}
+
+		if cfg.GitLabAPITokenFile != "" {
+			return &fileAuthSource{path: cfg.GitLabAPITokenFile, header: "Authorization", prefix: "Bearer "}, nil
+		}
+
+		if cfg.GitLabAPIToken == "" {
+			return nil, fmt.Errorf("%w: GITLAB_API_TOKEN, GITLAB_API_TOKEN_FILE or GITLAB_OAUTH_REFRESH_TOKEN must be set", ErrMissingToken)
+		}
+
+		return gitlab.OAuthTokenSource{TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.GitLabAPIToken})}, nil
+	}
+
+	if cfg.GitLabAPITokenFile != "" {
+		return &fileAuthSource{path: cfg.GitLabAPITokenFile, header: gitlab.AccessTokenHeaderName}, nil
+	}
+
+	if cfg.GitLabAPIToken == "" {
+		return nil, fmt.Errorf("%w: GITLAB_API_TOKEN or GITLAB_API_TOKEN_FILE must be set", ErrMissingToken)
+	}
+
+	return gitlab.AccessTokenAuthSource{Token: cfg.GitLabAPIToken}, nil
+}
+
+// newOAuthRefreshSource returns an OAuth2 source that gets a new access token from the refresh token whenever the
+// current one expired. GitLab rotates refresh tokens, the current one is only kept in memory.
+func newOAuthRefreshSource(cfg *config.Config) gitlab.AuthSource {
+	baseURL := strings.TrimSuffix(strings.TrimSuffix(cfg.GitLabURL, "/"), "/api/v4")
+
+	oauthConfig := &oauth2.Config{
+		ClientID:     cfg.GitLabOAuthClientID,
+		ClientSecret: cfg.GitLabOAuthClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  baseURL + "/oauth/authorize",
+			TokenURL: baseURL + "/oauth/token",
+		},
+	}
+
+	// Without an access token the first request refreshes it right away.
+	token := &oauth2.Token{RefreshToken: cfg.GitLabOAuthRefreshToken}
+
+	return gitlab.OAuthTokenSource{TokenSource: oauthConfig.TokenSource(context.Background(), token)}
+}
+
+// fileAuthSource reads the token from a file and reads it again once the file changed.
+type fileAuthSource struct {
+	path   string
+	header string
+	prefix string
+
+	mu      sync.Mutex
+	modTime time.Time
+	token   string
+}
+
+// Init reads the token, so a missing file is reported before the first request.
+func (s *fileAuthSource) Init(context.Context, *gitlab.Client) error {
+	_, err := s.load()
+
+	return err
+}
+
+// Header returns the authentication header with the current token.
+func (s *fileAuthSource) Header(context.Context) (string, string, error) {
+	token, err := s.load()
+	if err != nil {
+		return "", "", err
+	}
+
+	return s.header, s.prefix + token, nil
+}
+
+func (s *fileAuthSource) load() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return "", err
+	}
+
+	if s.token != "" && info.ModTime().Equal(s.modTime) {
+		return s.token, nil
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("%w: %s", errEmptyToken, s.path)
+	}
+
+	s.token, s.modTime = token, info.ModTime()
+
+	return token, nil
+}
@@ -0,0 +1,234 @@
+//nolint:lll,funlen
+package gitlab
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestNewAuthSource(t *testing.T) {
+	t.Parallel()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("glpat-file\n"), 0o600))
+
+	tests := []struct {
+		name          string
+		cfg           config.Config
+		expectHeader  string
+		expectValue   string
+		expectMissing bool
+	}{
+		{
+			name:         "access token",
+			cfg:          config.Config{GitLabAuth: config.GitLabAuthToken, GitLabAPIToken: "glpat-env"},
+			expectHeader: gitlab.AccessTokenHeaderName,
+			expectValue:  "glpat-env",
+		},
+		{
+			name:         "access token file",
+			cfg:          config.Config{GitLabAuth: config.GitLabAuthToken, GitLabAPIToken: "glpat-env", GitLabAPITokenFile: tokenFile},
+			expectHeader: gitlab.AccessTokenHeaderName,
+			expectValue:  "glpat-file",
+		},
+		{
+			name:          "missing access token",
+			cfg:           config.Config{GitLabAuth: config.GitLabAuthToken},
+			expectMissing: true,
+		},
+		{
+			name:         "job token",
+			cfg:          config.Config{GitLabAuth: config.GitLabAuthJobToken, GitLabJobToken: "job"},
+			expectHeader: gitlab.JobTokenHeaderName,
+			expectValue:  "job",
+		},
+		{
+			name:          "missing job token",
+			cfg:           config.Config{GitLabAuth: config.GitLabAuthJobToken, GitLabAPIToken: "glpat-env"},
+			expectMissing: true,
+		},
+		{
+			name:         "oauth token",
+			cfg:          config.Config{GitLabAuth: config.GitLabAuthOAuth, GitLabAPIToken: "oauth-env"},
+			expectHeader: "Authorization",
+			expectValue:  "Bearer oauth-env",
+		},
+		{
+			name:         "oauth token file",
+			cfg:          config.Config{GitLabAuth: config.GitLabAuthOAuth, GitLabAPITokenFile: tokenFile},
+			expectHeader: "Authorization",
+			expectValue:  "Bearer glpat-file",
+		},
+		{
+			name:          "missing oauth token",
+			cfg:           config.Config{GitLabAuth: config.GitLabAuthOAuth},
+			expectMissing: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			as, err := NewAuthSource(&tt.cfg)
+			if tt.expectMissing {
+				require.ErrorIs(t, err, ErrMissingToken)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoError(t, as.Init(t.Context(), nil))
+
+			header, value, err := as.Header(t.Context())
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectHeader, header)
+			assert.Equal(t, tt.expectValue, value)
+		})
+	}
+}
+
+func TestFileAuthSourceRotation(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0o600))
+
+	as := &fileAuthSource{path: path, header: gitlab.AccessTokenHeaderName}
+
+	_, value, err := as.Header(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "first", value)
+
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	_, value, err = as.Header(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "second", value)
+
+	require.NoError(t, os.WriteFile(path, []byte(" \n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+
+	_, _, err = as.Header(t.Context())
+	require.ErrorIs(t, err, errEmptyToken)
+
+	require.NoError(t, os.Remove(path))
+
+	_, _, err = as.Header(t.Context())
+	assert.Error(t, err)
+}
+
+func TestOAuthRefreshSource(t *testing.T) {
+	t.Parallel()
+
+	refreshes := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+
+		refreshes++
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access", "refresh_token": "rotated", "token_type": "Bearer", "expires_in": 7200,
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	as, err := NewAuthSource(&config.Config{
+		GitLabAuth:              config.GitLabAuthOAuth,
+		GitLabURL:               server.URL + "/api/v4",
+		GitLabOAuthClientID:     "client",
+		GitLabOAuthClientSecret: "secret",
+		GitLabOAuthRefreshToken: "refresh",
+	})
+	require.NoError(t, err)
+
+	for range 2 {
+		header, value, err := as.Header(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, "Authorization", header)
+		assert.Equal(t, "Bearer access", value)
+	}
+
+	assert.Equal(t, 1, refreshes)
+}
+
+func TestSelfCheck(t *testing.T) {
+	t.Parallel()
+
+	tokens := map[string]map[string]any{
+		"glpat-valid":    {"id": 2, "name": "renoglaab", "scopes": []string{"api"}, "expires_at": time.Now().AddDate(1, 0, 0).Format(time.DateOnly)},
+		"glpat-expiring": {"id": 3, "name": "renoglaab", "scopes": []string{"api"}, "expires_at": time.Now().AddDate(0, 0, 3).Format(time.DateOnly)},
+		"glpat-expired":  {"id": 4, "name": "renoglaab", "scopes": []string{"api"}, "expires_at": time.Now().AddDate(0, 0, -3).Format(time.DateOnly)},
+		"glpat-readonly": {"id": 5, "name": "renoglaab", "scopes": []string{"read_api"}},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := tokens[r.Header.Get(gitlab.AccessTokenHeaderName)]; !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": 1, "username": "project_1_bot", "bot": true})
+	})
+	mux.HandleFunc("GET /api/v4/personal_access_tokens/self", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(tokens[r.Header.Get(gitlab.AccessTokenHeaderName)])
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name        string
+		token       string
+		expectError error
+		expectFail  bool
+	}{
+		{name: "valid", token: "glpat-valid"},
+		{name: "expiring soon", token: "glpat-expiring"},
+		{name: "expired", token: "glpat-expired", expectError: ErrTokenExpired},
+		{name: "read only", token: "glpat-readonly", expectError: ErrMissingScope},
+		{name: "revoked", token: "glpat-revoked", expectFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := CreateGitLabClient(tt.token, server.URL)
+			require.NoError(t, err)
+
+			err = SelfCheck("", config.GitLabAuthToken, 7*24*time.Hour, client)
+			switch {
+			case tt.expectError != nil:
+				assert.ErrorIs(t, err, tt.expectError)
+			case tt.expectFail:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	client, err := CreateAuthSourceClient(gitlab.JobTokenAuthSource{Token: "job"}, server.URL)
+	require.NoError(t, err)
+	assert.NoError(t, SelfCheck("selfhosted", config.GitLabAuthJobToken, time.Hour, client))
+}
@@ -1,7 +1,13 @@
 package gitlab
 
 import (
+	"bytes"
+	"fmt"
+	"time"
+
 	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/metrics"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
@@ -9,11 +15,20 @@ import (
 var _ Client = (*ClientWrapper)(nil)
 
 // ClientWrapper wraps the gitlab.Client to implement the Client interface.
+// Repositories may be given with their instance prefix, e.g. "selfhosted:group/project",
+// which is stripped before calling the API.
 type ClientWrapper struct {
 	Client *gitlab.Client
 }
 
-// Client defines the methods used by ListProjectMergeRequests and pipelineSucceeded.
+// projectPath returns the project path of a repository, without its instance prefix.
+func projectPath(repo string) string {
+	_, project := SplitRepository(repo)
+
+	return project
+}
+
+// Client defines the GitLab API methods used by renoglaab.
 type Client interface {
 	ListProjectMergeRequests(
 		repo string, opts *gitlab.ListProjectMergeRequestsOptions,
@@ -24,6 +39,54 @@ type Client interface {
 	GetPipeline(
 		repo string, pipelineID int64,
 	) (*gitlab.Pipeline, *gitlab.Response, error)
+	ListPipelineJobs(
+		repo string, pipelineID int64, opts *gitlab.ListJobsOptions,
+	) ([]*gitlab.Job, *gitlab.Response, error)
+	CreatePipeline(
+		repo string, opts *gitlab.CreatePipelineOptions,
+	) (*gitlab.Pipeline, *gitlab.Response, error)
+	RetryPipeline(repo string, pipelineID int64) (*gitlab.Pipeline, *gitlab.Response, error)
+	RetryJob(repo string, jobID int64) (*gitlab.Job, *gitlab.Response, error)
+	GetJobTrace(repo string, jobID int64) (*bytes.Reader, *gitlab.Response, error)
+	GetMergeRequest(
+		repo string, mrIID int64, opts *gitlab.GetMergeRequestsOptions,
+	) (*gitlab.MergeRequest, *gitlab.Response, error)
+	RebaseMergeRequest(
+		repo string, mrIID int64, opts *gitlab.RebaseMergeRequestOptions,
+	) (*gitlab.Response, error)
+	UpdateMergeRequest(
+		repo string, mrIID int64, opts *gitlab.UpdateMergeRequestOptions,
+	) (*gitlab.MergeRequest, *gitlab.Response, error)
+	AcceptMergeRequest(
+		repo string, mrIID int64, opts *gitlab.AcceptMergeRequestOptions,
+	) (*gitlab.MergeRequest, *gitlab.Response, error)
+	CreateMergeRequest(
+		repo string, opts *gitlab.CreateMergeRequestOptions,
+	) (*gitlab.MergeRequest, *gitlab.Response, error)
+	GetBranch(repo, branch string) (*gitlab.Branch, *gitlab.Response, error)
+	CreateBranch(repo string, opts *gitlab.CreateBranchOptions) (*gitlab.Branch, *gitlab.Response, error)
+	DeleteBranch(repo, branch string) (*gitlab.Response, error)
+	RevertCommit(
+		repo, sha string, opts *gitlab.RevertCommitOptions,
+	) (*gitlab.Commit, *gitlab.Response, error)
+	CreateMergeRequestNote(
+		repo string, mrIID int64, opts *gitlab.CreateMergeRequestNoteOptions,
+	) (*gitlab.Note, *gitlab.Response, error)
+	ListMergeRequestNotes(
+		repo string, mrIID int64, opts *gitlab.ListMergeRequestNotesOptions,
+	) ([]*gitlab.Note, *gitlab.Response, error)
+	UpdateMergeRequestNote(
+		repo string, mrIID, noteID int64, opts *gitlab.UpdateMergeRequestNoteOptions,
+	) (*gitlab.Note, *gitlab.Response, error)
+	DeleteMergeRequestNote(repo string, mrIID, noteID int64) (*gitlab.Response, error)
+	GetMergeRequestApprovals(
+		repo string, mrIID int64,
+	) (*gitlab.MergeRequestApprovals, *gitlab.Response, error)
+	ApproveMergeRequest(
+		repo string, mrIID int64, opts *gitlab.ApproveMergeRequestOptions,
+	) (*gitlab.MergeRequestApprovals, *gitlab.Response, error)
+	CurrentUser() (*gitlab.User, *gitlab.Response, error)
+	GetProject(repo string) (*gitlab.Project, *gitlab.Response, error)
 }
 
 // ListProjectMergeRequests fetches the merge requests for a given repository.
@@ -34,7 +97,11 @@ func (w *ClientWrapper) ListProjectMergeRequests(
 		"repo": repo,
 	}).Debug("Fetching merge requests")
 
-	return w.Client.MergeRequests.ListProjectMergeRequests(repo, opts)
+	start := time.Now()
+	mrs, resp, err := w.Client.MergeRequests.ListProjectMergeRequests(projectPath(repo), opts)
+	metrics.ObserveAPIRequest("ListProjectMergeRequests", start, err)
+
+	return mrs, resp, err
 }
 
 // ListProjectPipelines fetches the pipelines for a given repository.
@@ -45,7 +112,11 @@ func (w *ClientWrapper) ListProjectPipelines(
 		"repo": repo,
 	}).Debug("Fetching pipelines")
 
-	return w.Client.Pipelines.ListProjectPipelines(repo, opts)
+	start := time.Now()
+	pipelines, resp, err := w.Client.Pipelines.ListProjectPipelines(projectPath(repo), opts)
+	metrics.ObserveAPIRequest("ListProjectPipelines", start, err)
+
+	return pipelines, resp, err
 }
 
 // GetPipeline fetches a specific pipeline by its ID for a given repository.
@@ -57,21 +128,428 @@ func (w *ClientWrapper) GetPipeline(
 		"pipelineID": pipelineID,
 	}).Debug("Fetching pipeline")
 
-	return w.Client.Pipelines.GetPipeline(repo, pipelineID)
+	start := time.Now()
+	pipeline, resp, err := w.Client.Pipelines.GetPipeline(projectPath(repo), pipelineID)
+	metrics.ObserveAPIRequest("GetPipeline", start, err)
+
+	return pipeline, resp, err
+}
+
+// ListPipelineJobs fetches the jobs of a pipeline.
+func (w *ClientWrapper) ListPipelineJobs(
+	repo string, pipelineID int64, opts *gitlab.ListJobsOptions,
+) ([]*gitlab.Job, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":       repo,
+		"pipelineID": pipelineID,
+	}).Debug("Fetching pipeline jobs")
+
+	start := time.Now()
+	jobs, resp, err := w.Client.Jobs.ListPipelineJobs(projectPath(repo), pipelineID, opts)
+	metrics.ObserveAPIRequest("ListPipelineJobs", start, err)
+
+	return jobs, resp, err
+}
+
+// CreatePipeline runs a new pipeline for a ref.
+func (w *ClientWrapper) CreatePipeline(
+	repo string, opts *gitlab.CreatePipelineOptions,
+) (*gitlab.Pipeline, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo": repo,
+	}).Debug("Creating pipeline")
+
+	start := time.Now()
+	pipeline, resp, err := w.Client.Pipelines.CreatePipeline(projectPath(repo), opts)
+	metrics.ObserveAPIRequest("CreatePipeline", start, err)
+
+	return pipeline, resp, err
+}
+
+// RetryPipeline retries the failed and canceled jobs of a pipeline.
+func (w *ClientWrapper) RetryPipeline(repo string, pipelineID int64) (*gitlab.Pipeline, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":       repo,
+		"pipelineID": pipelineID,
+	}).Debug("Retrying pipeline")
+
+	start := time.Now()
+	pipeline, resp, err := w.Client.Pipelines.RetryPipelineBuild(projectPath(repo), pipelineID)
+	metrics.ObserveAPIRequest("RetryPipeline", start, err)
+
+	return pipeline, resp, err
+}
+
+// RetryJob retries a single job.
+func (w *ClientWrapper) RetryJob(repo string, jobID int64) (*gitlab.Job, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"jobID": jobID,
+	}).Debug("Retrying job")
+
+	start := time.Now()
+	job, resp, err := w.Client.Jobs.RetryJob(projectPath(repo), jobID)
+	metrics.ObserveAPIRequest("RetryJob", start, err)
+
+	return job, resp, err
+}
+
+// GetJobTrace fetches the log of a job.
+func (w *ClientWrapper) GetJobTrace(repo string, jobID int64) (*bytes.Reader, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"jobID": jobID,
+	}).Debug("Fetching job trace")
+
+	start := time.Now()
+	trace, resp, err := w.Client.Jobs.GetTraceFile(projectPath(repo), jobID)
+	metrics.ObserveAPIRequest("GetJobTrace", start, err)
+
+	return trace, resp, err
+}
+
+// GetMergeRequest fetches a single merge request.
+func (w *ClientWrapper) GetMergeRequest(
+	repo string, mrIID int64, opts *gitlab.GetMergeRequestsOptions,
+) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"mrIID": mrIID,
+	}).Debug("Fetching merge request")
+
+	start := time.Now()
+	mr, resp, err := w.Client.MergeRequests.GetMergeRequest(projectPath(repo), mrIID, opts)
+	metrics.ObserveAPIRequest("GetMergeRequest", start, err)
+
+	return mr, resp, err
+}
+
+// RebaseMergeRequest rebases the source branch of a merge request onto its target branch.
+func (w *ClientWrapper) RebaseMergeRequest(
+	repo string, mrIID int64, opts *gitlab.RebaseMergeRequestOptions,
+) (*gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"mrIID": mrIID,
+	}).Debug("Rebasing merge request")
+
+	start := time.Now()
+	resp, err := w.Client.MergeRequests.RebaseMergeRequest(projectPath(repo), mrIID, opts)
+	metrics.ObserveAPIRequest("RebaseMergeRequest", start, err)
+
+	return resp, err
+}
+
+// UpdateMergeRequest changes the attributes of a merge request, e.g. its labels.
+func (w *ClientWrapper) UpdateMergeRequest(
+	repo string, mrIID int64, opts *gitlab.UpdateMergeRequestOptions,
+) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"mrIID": mrIID,
+	}).Debug("Updating merge request")
+
+	start := time.Now()
+	mr, resp, err := w.Client.MergeRequests.UpdateMergeRequest(projectPath(repo), mrIID, opts)
+	metrics.ObserveAPIRequest("UpdateMergeRequest", start, err)
+
+	return mr, resp, err
+}
+
+// AcceptMergeRequest merges a merge request into its target branch.
+func (w *ClientWrapper) AcceptMergeRequest(
+	repo string, mrIID int64, opts *gitlab.AcceptMergeRequestOptions,
+) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"mrIID": mrIID,
+	}).Debug("Merging merge request")
+
+	start := time.Now()
+	mr, resp, err := w.Client.MergeRequests.AcceptMergeRequest(projectPath(repo), mrIID, opts)
+	metrics.ObserveAPIRequest("AcceptMergeRequest", start, err)
+
+	return mr, resp, err
+}
+
+// CreateMergeRequest opens a new merge request.
+func (w *ClientWrapper) CreateMergeRequest(
+	repo string, opts *gitlab.CreateMergeRequestOptions,
+) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo": repo,
+	}).Debug("Creating merge request")
+
+	start := time.Now()
+	mr, resp, err := w.Client.MergeRequests.CreateMergeRequest(projectPath(repo), opts)
+	metrics.ObserveAPIRequest("CreateMergeRequest", start, err)
+
+	return mr, resp, err
+}
+
+// GetBranch fetches a branch.
+func (w *ClientWrapper) GetBranch(repo, branch string) (*gitlab.Branch, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":   repo,
+		"branch": branch,
+	}).Debug("Getting branch")
+
+	start := time.Now()
+	b, resp, err := w.Client.Branches.GetBranch(projectPath(repo), branch)
+	metrics.ObserveAPIRequest("GetBranch", start, err)
+
+	return b, resp, err
+}
+
+// CreateBranch creates a new branch from a ref.
+func (w *ClientWrapper) CreateBranch(
+	repo string, opts *gitlab.CreateBranchOptions,
+) (*gitlab.Branch, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo": repo,
+	}).Debug("Creating branch")
+
+	start := time.Now()
+	branch, resp, err := w.Client.Branches.CreateBranch(projectPath(repo), opts)
+	metrics.ObserveAPIRequest("CreateBranch", start, err)
+
+	return branch, resp, err
+}
+
+// DeleteBranch deletes a branch.
+func (w *ClientWrapper) DeleteBranch(repo, branch string) (*gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":   repo,
+		"branch": branch,
+	}).Debug("Deleting branch")
+
+	start := time.Now()
+	resp, err := w.Client.Branches.DeleteBranch(projectPath(repo), branch)
+	metrics.ObserveAPIRequest("DeleteBranch", start, err)
+
+	return resp, err
+}
+
+// RevertCommit reverts a commit on the given branch.
+func (w *ClientWrapper) RevertCommit(
+	repo, sha string, opts *gitlab.RevertCommitOptions,
+) (*gitlab.Commit, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo": repo,
+		"sha":  sha,
+	}).Debug("Reverting commit")
+
+	start := time.Now()
+	commit, resp, err := w.Client.Commits.RevertCommit(projectPath(repo), sha, opts)
+	metrics.ObserveAPIRequest("RevertCommit", start, err)
+
+	return commit, resp, err
+}
+
+// CreateMergeRequestNote adds a note to a merge request.
+func (w *ClientWrapper) CreateMergeRequestNote(
+	repo string, mrIID int64, opts *gitlab.CreateMergeRequestNoteOptions,
+) (*gitlab.Note, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"mrIID": mrIID,
+	}).Debug("Creating merge request note")
+
+	start := time.Now()
+	note, resp, err := w.Client.Notes.CreateMergeRequestNote(projectPath(repo), mrIID, opts)
+	metrics.ObserveAPIRequest("CreateMergeRequestNote", start, err)
+
+	return note, resp, err
+}
+
+// ListMergeRequestNotes fetches the notes of a merge request.
+func (w *ClientWrapper) ListMergeRequestNotes(
+	repo string, mrIID int64, opts *gitlab.ListMergeRequestNotesOptions,
+) ([]*gitlab.Note, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"mrIID": mrIID,
+	}).Debug("Fetching merge request notes")
+
+	start := time.Now()
+	notes, resp, err := w.Client.Notes.ListMergeRequestNotes(projectPath(repo), mrIID, opts)
+	metrics.ObserveAPIRequest("ListMergeRequestNotes", start, err)
+
+	return notes, resp, err
+}
+
+// UpdateMergeRequestNote changes the body of an existing merge request note.
+func (w *ClientWrapper) UpdateMergeRequestNote(
+	repo string, mrIID, noteID int64, opts *gitlab.UpdateMergeRequestNoteOptions,
+) (*gitlab.Note, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":   repo,
+		"mrIID":  mrIID,
+		"noteID": noteID,
+	}).Debug("Updating merge request note")
+
+	start := time.Now()
+	note, resp, err := w.Client.Notes.UpdateMergeRequestNote(projectPath(repo), mrIID, noteID, opts)
+	metrics.ObserveAPIRequest("UpdateMergeRequestNote", start, err)
+
+	return note, resp, err
+}
+
+// DeleteMergeRequestNote removes a note from a merge request.
+func (w *ClientWrapper) DeleteMergeRequestNote(repo string, mrIID, noteID int64) (*gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":   repo,
+		"mrIID":  mrIID,
+		"noteID": noteID,
+	}).Debug("Deleting merge request note")
+
+	start := time.Now()
+	resp, err := w.Client.Notes.DeleteMergeRequestNote(projectPath(repo), mrIID, noteID)
+	metrics.ObserveAPIRequest("DeleteMergeRequestNote", start, err)
+
+	return resp, err
+}
+
+// GetMergeRequestApprovals fetches the approval state of a merge request.
+func (w *ClientWrapper) GetMergeRequestApprovals(
+	repo string, mrIID int64,
+) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"mrIID": mrIID,
+	}).Debug("Fetching merge request approvals")
+
+	start := time.Now()
+	approvals, resp, err := w.Client.MergeRequestApprovals.GetConfiguration(projectPath(repo), mrIID)
+	metrics.ObserveAPIRequest("GetMergeRequestApprovals", start, err)
+
+	return approvals, resp, err
+}
+
+// ApproveMergeRequest approves a merge request as the token user.
+func (w *ClientWrapper) ApproveMergeRequest(
+	repo string, mrIID int64, opts *gitlab.ApproveMergeRequestOptions,
+) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo":  repo,
+		"mrIID": mrIID,
+	}).Debug("Approving merge request")
+
+	start := time.Now()
+	approvals, resp, err := w.Client.MergeRequestApprovals.ApproveMergeRequest(projectPath(repo), mrIID, opts)
+	metrics.ObserveAPIRequest("ApproveMergeRequest", start, err)
+
+	return approvals, resp, err
+}
+
+// CurrentUser fetches the user the API token belongs to.
+func (w *ClientWrapper) CurrentUser() (*gitlab.User, *gitlab.Response, error) {
+	logrus.Debug("Fetching current user")
+
+	start := time.Now()
+	user, resp, err := w.Client.Users.CurrentUser()
+	metrics.ObserveAPIRequest("CurrentUser", start, err)
+
+	return user, resp, err
+}
+
+// GetProject fetches a project, e.g. to look up its default branch.
+func (w *ClientWrapper) GetProject(repo string) (*gitlab.Project, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo": repo,
+	}).Debug("Fetching project")
+
+	start := time.Now()
+	project, resp, err := w.Client.Projects.GetProject(projectPath(repo), nil)
+	metrics.ObserveAPIRequest("GetProject", start, err)
+
+	return project, resp, err
+}
+
+// GetProjectVariable fetches a CI/CD variable of a project.
+func (w *ClientWrapper) GetProjectVariable(repo, key string) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo": repo,
+		"key":  key,
+	}).Debug("Fetching project variable")
+
+	start := time.Now()
+	variable, resp, err := w.Client.ProjectVariables.GetVariable(projectPath(repo), key, nil)
+	metrics.ObserveAPIRequest("GetProjectVariable", start, err)
+
+	return variable, resp, err
+}
+
+// CreateProjectVariable adds a CI/CD variable to a project.
+func (w *ClientWrapper) CreateProjectVariable(
+	repo string, opts *gitlab.CreateProjectVariableOptions,
+) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo": repo,
+	}).Debug("Creating project variable")
+
+	start := time.Now()
+	variable, resp, err := w.Client.ProjectVariables.CreateVariable(projectPath(repo), opts)
+	metrics.ObserveAPIRequest("CreateProjectVariable", start, err)
+
+	return variable, resp, err
+}
+
+// UpdateProjectVariable changes a CI/CD variable of a project.
+func (w *ClientWrapper) UpdateProjectVariable(
+	repo, key string, opts *gitlab.UpdateProjectVariableOptions,
+) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	logrus.WithFields(logrus.Fields{
+		"repo": repo,
+		"key":  key,
+	}).Debug("Updating project variable")
+
+	start := time.Now()
+	variable, resp, err := w.Client.ProjectVariables.UpdateVariable(projectPath(repo), key, opts)
+	metrics.ObserveAPIRequest("UpdateProjectVariable", start, err)
+
+	return variable, resp, err
+}
+
+// CurrentToken returns the access token the client authenticates with.
+// It only works for personal, project and group access tokens.
+func (w *ClientWrapper) CurrentToken() (*gitlab.PersonalAccessToken, *gitlab.Response, error) {
+	logrus.Debug("Fetching current access token")
+
+	start := time.Now()
+	token, resp, err := w.Client.PersonalAccessTokens.GetSinglePersonalAccessToken()
+	metrics.ObserveAPIRequest("CurrentToken", start, err)
+
+	return token, resp, err
 }
 
 // CreateGitLabClient initializes a new GitLab client.
 func CreateGitLabClient(gitlabToken string, gitlabBaseURL string) (*ClientWrapper, error) {
 	if gitlabToken == "" {
-		logrus.Fatal("GITLAB_API_TOKEN must be set")
+		return nil, fmt.Errorf("%w: GITLAB_API_TOKEN must be set", ErrMissingToken)
 	}
 
-	client, err := gitlab.NewClient(gitlabToken, gitlab.WithBaseURL(gitlabBaseURL))
+	return CreateAuthSourceClient(gitlab.AccessTokenAuthSource{Token: gitlabToken}, gitlabBaseURL)
+}
+
+// CreateAuthSourceClient initializes a new GitLab client authenticating with the given source.
+func CreateAuthSourceClient(as gitlab.AuthSource, gitlabBaseURL string) (*ClientWrapper, error) {
+	client, err := gitlab.NewAuthSourceClient(as, gitlab.WithBaseURL(gitlabBaseURL))
 	if err != nil {
-		logrus.WithError(err).Fatal("Failed to create GitLab client")
+		return nil, fmt.Errorf("failed to create GitLab client: %w", err)
 	}
 
 	logrus.Debug("GitLab client successfully initialized")
 
 	return &ClientWrapper{Client: client}, nil
 }
+
+// CreateDefaultClient initializes a client for GITLAB_URL using the authentication set by GITLAB_AUTH.
+func CreateDefaultClient(cfg *config.Config) (*ClientWrapper, error) {
+	as, err := NewAuthSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateAuthSourceClient(as, cfg.GitLabURL)
+}
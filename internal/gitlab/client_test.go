@@ -4,60 +4,52 @@ package gitlab_test
 import (
 	"testing"
 
-	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
-	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
 func TestCreateGitLabClient(t *testing.T) {
-	var fatalCalled bool
-
-	logrus.StandardLogger().ExitFunc = func(int) { fatalCalled = true }
-
 	tests := []struct {
 		name          string
 		gitlabToken   string
 		gitlabBaseURL string
-		mockError     error
-		expectFatal   bool
+		expectError   error
+		expectMessage string
 	}{
 		{
 			name:          "Valid token and URL",
 			gitlabToken:   "valid_token",
 			gitlabBaseURL: "https://gitlab.com",
-			mockError:     nil,
-			expectFatal:   false,
 		},
 		{
 			name:          "Empty token",
 			gitlabToken:   "",
 			gitlabBaseURL: "https://gitlab.com",
-			mockError:     nil,
-			expectFatal:   true,
+			expectError:   gl.ErrMissingToken,
+		},
+		{
+			name:          "Invalid URL",
+			gitlabToken:   "valid_token",
+			gitlabBaseURL: "://gitlab.com",
+			expectMessage: "failed to create GitLab client",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			fatalCalled = false
-
-			// Mock the gitlab.NewClient function
-			newGitLabClient := func(_ string, options ...gitlab.ClientOptionFunc) (*gitlab.Client, error) {
-				return nil, tt.mockError
-			}
-
-			// Temporarily replace the global newGitLabClient with the local mock
-			originalNewGitLabClient := newGitLabClient
-			defer func() { newGitLabClient = originalNewGitLabClient }()
-
-			client, _ := gl.CreateGitLabClient(tt.gitlabToken, tt.gitlabBaseURL)
-
-			if tt.expectFatal {
-				assert.True(t, fatalCalled, "Expected fatal to be called")
-			} else {
+			client, err := gl.CreateGitLabClient(tt.gitlabToken, tt.gitlabBaseURL)
+
+			switch {
+			case tt.expectError != nil:
+				require.ErrorIs(t, err, tt.expectError)
+				assert.Nil(t, client)
+			case tt.expectMessage != "":
+				require.ErrorContains(t, err, tt.expectMessage)
+				assert.Nil(t, client)
+			default:
+				require.NoError(t, err)
 				assert.NotNil(t, client, "Expected client to be created")
-				assert.False(t, fatalCalled, "Expected fatal not to be called")
 			}
 		})
 	}
@@ -0,0 +1,62 @@
+package gitlab
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xMoelletschi/renoglaab/internal/config"
+)
+
+var ErrUnknownInstance = errors.New("unknown GitLab instance")
+
+// SplitRepository splits a repository entry such as "selfhosted:group/project" into the instance name and the project path.
+// Entries without a prefix belong to the default instance, whose name is empty.
+func SplitRepository(repo string) (string, string) {
+	instance, project, found := strings.Cut(repo, ":")
+	if !found {
+		return "", repo
+	}
+
+	return instance, project
+}
+
+// CreateClients creates one client per instance used by the repositories, keyed by instance name.
+func CreateClients(cfg *config.Config, repositories []string) (map[string]*ClientWrapper, error) {
+	clients := map[string]*ClientWrapper{}
+
+	for _, repo := range repositories {
+		name, _ := SplitRepository(repo)
+		if _, ok := clients[name]; ok {
+			continue
+		}
+
+		var (
+			client *ClientWrapper
+			err    error
+		)
+
+		if name == "" {
+			client, err = CreateDefaultClient(cfg)
+		} else {
+			instance, ok := cfg.GitLabInstance(name)
+			if !ok {
+				return nil, fmt.Errorf("%w %q in repository %s", ErrUnknownInstance, name, repo)
+			}
+
+			if instance.APIToken == "" {
+				return nil, fmt.Errorf("%w for GitLab instance %q: %s must be set", ErrMissingToken, name, instance.TokenKey())
+			}
+
+			client, err = CreateGitLabClient(instance.APIToken, instance.URL)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients[name] = client
+	}
+
+	return clients, nil
+}
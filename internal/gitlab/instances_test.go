@@ -0,0 +1,81 @@
+package gitlab_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+)
+
+func TestSplitRepository(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		repo             string
+		expectedInstance string
+		expectedProject  string
+	}{
+		{repo: "group/project", expectedInstance: "", expectedProject: "group/project"},
+		{repo: "selfhosted:group/sub/project", expectedInstance: "selfhosted", expectedProject: "group/sub/project"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			t.Parallel()
+
+			instance, project := gl.SplitRepository(tt.repo)
+			assert.Equal(t, tt.expectedInstance, instance)
+			assert.Equal(t, tt.expectedProject, project)
+		})
+	}
+}
+
+func TestCreateClients(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GitLabAPIToken: "token",
+		GitLabURL:      "https://gitlab.com",
+		GitLabInstances: []config.GitLabInstance{
+			{Name: "selfhosted", URL: "https://gitlab.example.com", APIToken: "selfhosted-token"},
+		},
+	}
+
+	clients, err := gl.CreateClients(cfg, []string{"group/a", "selfhosted:group/b", "selfhosted:group/c"})
+	require.NoError(t, err)
+	require.Len(t, clients, 2)
+	assert.Equal(t, "https://gitlab.com/api/v4/", clients[""].Client.BaseURL().String())
+	assert.Equal(t, "https://gitlab.example.com/api/v4/", clients["selfhosted"].Client.BaseURL().String())
+
+	_, err = gl.CreateClients(cfg, []string{"other:group/d"})
+	assert.ErrorIs(t, err, gl.ErrUnknownInstance)
+
+	cfg.GitLabInstances = append(cfg.GitLabInstances, config.GitLabInstance{Name: "self-managed", URL: "https://gitlab.example.org"})
+
+	_, err = gl.CreateClients(cfg, []string{"self-managed:group/e"})
+	require.ErrorIs(t, err, gl.ErrMissingToken)
+	assert.ErrorContains(t, err, `GitLab instance "self-managed": GITLAB_API_TOKEN_SELF_MANAGED must be set`)
+}
+
+func TestClientStripsInstancePrefix(t *testing.T) {
+	t.Parallel()
+
+	var path string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := gl.CreateGitLabClient("token", server.URL)
+	require.NoError(t, err)
+
+	_, _, err = client.GetProject("selfhosted:group/project")
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v4/projects/group%2Fproject", path)
+}
@@ -0,0 +1,87 @@
+package gitlab
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+)
+
+const (
+	defaultInstanceName = "default"
+	apiScope            = "api"
+)
+
+// Reasons for a token failing the self-check.
+var (
+	ErrMissingScope = errors.New("token lacks the api scope")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// SelfCheck logs who the client acts as and which scopes its token has, so a wrong token shows up
+// before any project is processed. It warns if the token expires within expiryWarning.
+// It returns an error if the current user can't be fetched, or the token expired or lacks the api scope.
+func SelfCheck(instance, auth string, expiryWarning time.Duration, client *ClientWrapper) error {
+	if instance == "" {
+		instance = defaultInstanceName
+	}
+
+	fields := logrus.Fields{"instance": instance, "url": client.Client.BaseURL().String(), "auth": auth}
+
+	// Job tokens can't look up their user or token, so there is nothing to check.
+	if auth == config.GitLabAuthJobToken {
+		logrus.WithFields(fields).Info("Authenticated with a CI job token, only endpoints allowing job tokens will work")
+
+		return nil
+	}
+
+	user, _, err := client.CurrentUser()
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Self-check failed, cannot fetch the current user")
+
+		return err
+	}
+
+	fields["user"] = user.Username
+	fields["bot"] = user.Bot
+
+	// OAuth2 tokens aren't access tokens, so their scopes can't be fetched this way.
+	token, _, err := client.CurrentToken()
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Debug("Failed to fetch the current access token")
+		logrus.WithFields(fields).Info("Authenticated, token scopes are unknown")
+
+		return nil
+	}
+
+	fields["token"] = token.Name
+	fields["scopes"] = token.Scopes
+
+	if !slices.Contains(token.Scopes, apiScope) {
+		logrus.WithFields(fields).Error("Self-check failed, the token can't approve or merge")
+
+		return ErrMissingScope
+	}
+
+	if token.ExpiresAt != nil {
+		expiresAt := time.Time(*token.ExpiresAt)
+		fields["expires_at"] = expiresAt.Format(time.DateOnly)
+
+		if time.Now().After(expiresAt) {
+			logrus.WithFields(fields).Error("Self-check failed, the token expired")
+
+			return fmt.Errorf("%w on %s", ErrTokenExpired, expiresAt.Format(time.DateOnly))
+		}
+
+		if time.Until(expiresAt) < expiryWarning {
+			logrus.WithFields(fields).Warn("Token expires soon, rotate it")
+		}
+	}
+
+	logrus.WithFields(fields).Info("Authenticated")
+
+	return nil
+}
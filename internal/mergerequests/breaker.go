@@ -0,0 +1,98 @@
+package mergerequests
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// circuitOpen updates the circuit breaker of a project from its default branch pipeline
+// and reports whether the project is paused.
+// A failed pipeline pauses the project, a pipeline that started after the pause and succeeded resumes it.
+func circuitOpen(repo string, store *state.Store, client gl.Client) bool {
+	project := store.Project(repo)
+	fields := logrus.Fields{"repository": repo}
+
+	pipeline, err := latestDefaultBranchPipeline(repo, client)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to check default branch pipeline")
+
+		return project.Paused
+	}
+
+	switch {
+	case pipeline == nil:
+	case pipeline.Status == jobStatusFailed && !project.Paused:
+		pauseProject(&project, fmt.Sprintf("default branch pipeline #%d failed", pipeline.ID))
+		logrus.WithFields(fields).WithField("reason", project.PausedReason).Warn("Paused project")
+	case pipeline.Status == jobStatusSuccess && project.Paused &&
+		pipeline.CreatedAt != nil && pipeline.CreatedAt.After(project.PausedAt):
+		logrus.WithFields(fields).WithField("pipeline_id", pipeline.ID).Info("Default branch pipeline succeeded, resumed project")
+
+		project.Paused = false
+		project.PausedReason = ""
+		project.PausedAt = time.Time{}
+		project.ConsecutiveFailures = 0
+	}
+
+	store.SetProject(repo, project)
+
+	if project.Paused {
+		logrus.WithFields(fields).WithField("reason", project.PausedReason).Warn("Project is paused, skipping")
+	}
+
+	return project.Paused
+}
+
+// recordActionFailures counts failed actions across runs and pauses the project once the threshold is reached.
+// A run without failed actions resets the count.
+func recordActionFailures(config config.Config, repo string, store *state.Store, failures int) {
+	project := store.Project(repo)
+
+	if failures == 0 {
+		project.ConsecutiveFailures = 0
+		store.SetProject(repo, project)
+
+		return
+	}
+
+	project.ConsecutiveFailures += failures
+
+	if !project.Paused && project.ConsecutiveFailures >= config.CircuitBreakerThreshold {
+		pauseProject(&project, fmt.Sprintf("%d consecutive actions failed", project.ConsecutiveFailures))
+		logrus.WithFields(logrus.Fields{
+			"repository": repo, "reason": project.PausedReason,
+		}).Warn("Paused project")
+	}
+
+	store.SetProject(repo, project)
+}
+
+func pauseProject(project *state.Project, reason string) {
+	project.Paused = true
+	project.PausedReason = reason
+	project.PausedAt = time.Now()
+}
+
+// latestDefaultBranchPipeline returns the most recent pipeline of the project's default branch, if any.
+func latestDefaultBranchPipeline(repo string, client gl.Client) (*gitlab.PipelineInfo, error) {
+	project, _, err := client.GetProject(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	pipelines, _, err := client.ListProjectPipelines(repo, &gitlab.ListProjectPipelinesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 1},
+		Ref:         gitlab.Ptr(project.DefaultBranch),
+	})
+	if err != nil || len(pipelines) == 0 {
+		return nil, err
+	}
+
+	return pipelines[0], nil
+}
@@ -0,0 +1,118 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestCircuitOpen(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	pausedAt := time.Now().Add(-time.Hour)
+	paused := state.Project{Paused: true, PausedReason: "5 consecutive actions failed", PausedAt: pausedAt, ConsecutiveFailures: 5}
+
+	tests := []struct {
+		name         string
+		project      state.Project
+		pipelines    []*gitlab.PipelineInfo
+		projectErr   error
+		expectOpen   bool
+		expectReason string
+	}{
+		{
+			name:      "Green default branch",
+			pipelines: []*gitlab.PipelineInfo{{ID: 10, Status: "success", CreatedAt: gitlab.Ptr(time.Now())}},
+		},
+		{
+			name:         "Red default branch pauses",
+			pipelines:    []*gitlab.PipelineInfo{{ID: 10, Status: "failed", CreatedAt: gitlab.Ptr(time.Now())}},
+			expectOpen:   true,
+			expectReason: "default branch pipeline #10 failed",
+		},
+		{
+			name:         "Stays paused until a new pipeline succeeds",
+			project:      paused,
+			pipelines:    []*gitlab.PipelineInfo{{ID: 10, Status: "success", CreatedAt: gitlab.Ptr(pausedAt.Add(-time.Minute))}},
+			expectOpen:   true,
+			expectReason: paused.PausedReason,
+		},
+		{
+			name:         "Stays paused while the pipeline runs",
+			project:      paused,
+			pipelines:    []*gitlab.PipelineInfo{{ID: 11, Status: "running", CreatedAt: gitlab.Ptr(time.Now())}},
+			expectOpen:   true,
+			expectReason: paused.PausedReason,
+		},
+		{
+			name:      "Resumes after a new pipeline succeeded",
+			project:   paused,
+			pipelines: []*gitlab.PipelineInfo{{ID: 11, Status: "success", CreatedAt: gitlab.Ptr(time.Now())}},
+		},
+		{
+			name:         "Keeps the state if the project can't be fetched",
+			project:      paused,
+			projectErr:   errors.New("not found"),
+			expectOpen:   true,
+			expectReason: paused.PausedReason,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, err := state.Open(nil)
+			require.NoError(t, err)
+			store.SetProject(repo, tt.project)
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("GetProject", repo).Return(&gitlab.Project{DefaultBranch: "main"}, tt.projectErr)
+			mockClient.On("ListProjectPipelines", repo, mock.MatchedBy(func(opts *gitlab.ListProjectPipelinesOptions) bool {
+				return *opts.Ref == "main"
+			})).Return(tt.pipelines, nil).Maybe()
+
+			assert.Equal(t, tt.expectOpen, circuitOpen(repo, store, mockClient))
+
+			project := store.Project(repo)
+			assert.Equal(t, tt.expectOpen, project.Paused)
+			assert.Equal(t, tt.expectReason, project.PausedReason)
+
+			if !tt.expectOpen {
+				assert.Equal(t, state.Project{}, project)
+			}
+		})
+	}
+}
+
+func TestRecordActionFailures(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	cfg := config.Config{CircuitBreakerThreshold: 3}
+
+	store, err := state.Open(nil)
+	require.NoError(t, err)
+
+	recordActionFailures(cfg, repo, store, 2)
+	assert.Equal(t, state.Project{ConsecutiveFailures: 2}, store.Project(repo))
+
+	recordActionFailures(cfg, repo, store, 0)
+	assert.Equal(t, state.Project{}, store.Project(repo))
+
+	recordActionFailures(cfg, repo, store, 2)
+	recordActionFailures(cfg, repo, store, 1)
+
+	project := store.Project(repo)
+	assert.True(t, project.Paused)
+	assert.Equal(t, "3 consecutive actions failed", project.PausedReason)
+}
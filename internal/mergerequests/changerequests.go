@@ -0,0 +1,170 @@
+package mergerequests
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/metrics"
+	"github.com/xMoelletschi/renoglaab/internal/provider"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// ReconcileChangeRequests approves the change requests of a repository on any provider.
+// The change requests run through the same filters as GitLab merge requests, but only the filters
+// and actions every provider supports are available. The others are handled by ReconcileProjectMergeRequests.
+func ReconcileChangeRequests(config config.Config, repo string, p provider.Provider) {
+	start := time.Now()
+	defer func() {
+		metrics.ReconcileDuration.WithLabelValues(repo).Observe(time.Since(start).Seconds())
+	}()
+
+	fields := logrus.Fields{"provider": p.Name(), "repository": repo}
+
+	user, err := p.CurrentUser()
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to fetch current user, cannot detect own approvals")
+	}
+
+	opts := provider.ListOptions{}
+
+	if config.FilterByAuthorUsername {
+		opts.Author = config.AuthorUsername
+	}
+
+	if config.FilterByLabels {
+		opts.Labels = config.Labels
+	}
+
+	changeRequests, err := p.ListChangeRequests(repo, opts)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to list change requests")
+
+		return
+	}
+
+	waiting := 0
+
+	for _, cr := range changeRequests {
+		metrics.MergeRequestsEvaluated.WithLabelValues(repo).Inc()
+
+		c, r := evaluateChangeRequest(config, repo, cr, p)
+		if r != nil {
+			logrus.WithError(r.err).WithFields(fields).WithFields(logrus.Fields{
+				"mr_id": cr.ID, "filter": r.filter,
+			}).Debug("Change request did not pass the filters")
+
+			waiting++
+
+			continue
+		}
+
+		approved, err := approveChangeRequest(config, repo, cr, newCommentData(c), user, p)
+		if err != nil {
+			logrus.WithError(err).WithFields(fields).WithField("mr_id", cr.ID).Error("Failed to approve change request")
+
+			continue
+		}
+
+		if approved {
+			metrics.MergeRequestsApproved.WithLabelValues(repo).Inc()
+			logrus.WithFields(fields).WithField("mr_id", cr.ID).Info("Approved MR")
+		}
+	}
+
+	metrics.MergeRequestsWaiting.WithLabelValues(repo).Set(float64(waiting))
+}
+
+// evaluateChangeRequest runs the registry filters on a listed change request, like evaluateFilters for a GitLab MR.
+// The GitLab specific filters are rejected by the configuration for other providers, so they are never enabled.
+func evaluateChangeRequest(
+	config config.Config, repo string, cr *provider.ChangeRequest, p provider.Provider,
+) (*candidate, *rejection) {
+	fc := &FilterContext{
+		Config: config, Repo: repo, MR: mergeRequestOf(cr), Provider: p, ChangeRequest: cr, Listed: true,
+	}
+
+	return runFilters(fc, evaluateOptions{listed: true})
+}
+
+// mergeRequestOf maps a change request onto the merge request fields the filters check.
+func mergeRequestOf(cr *provider.ChangeRequest) *gitlab.BasicMergeRequest {
+	return &gitlab.BasicMergeRequest{
+		IID:          cr.ID,
+		Title:        cr.Title,
+		Description:  cr.Description,
+		State:        stateOpen,
+		Author:       &gitlab.BasicUser{Username: cr.Author},
+		SourceBranch: cr.SourceBranch,
+		TargetBranch: cr.TargetBranch,
+		SHA:          cr.SHA,
+		Labels:       gitlab.Labels(cr.Labels),
+		Draft:        cr.Draft,
+		WebURL:       cr.WebURL,
+	}
+}
+
+// evaluateCheckStatus checks the CI result of a change request on another provider for the pipeline filter.
+// The check status stands in for the pipeline, so traces and comment templates show it the same way.
+func evaluateCheckStatus(fc *FilterContext) Verdict {
+	status, err := fc.Provider.CheckStatus(fc.Repo, fc.ChangeRequest)
+	if err != nil {
+		return failed(err, nil)
+	}
+
+	fc.Pipeline = &gitlab.Pipeline{Status: status.Status, WebURL: status.WebURL}
+
+	if err := checkStatusError(status); err != nil {
+		return failed(err, pipelineEvidence(fc.Pipeline))
+	}
+
+	return passed(pipelineEvidence(fc.Pipeline))
+}
+
+// checkStatusError maps a check status to the pipeline rejection it stands for.
+func checkStatusError(status *provider.CheckStatus) error {
+	switch status.State {
+	case provider.CheckSuccess:
+		return nil
+	case provider.CheckNone:
+		return errNoPipeline
+	case provider.CheckPending:
+		return fmt.Errorf("%w: checks are %s", errPipelineRunning, status.Status)
+	default:
+		return fmt.Errorf("%w: checks are %s", errPipelineNotSucceeded, status.Status)
+	}
+}
+
+// approveChangeRequest approves the change request unless the current user already did at its head commit.
+// It returns false if nothing had to be done.
+func approveChangeRequest(
+	config config.Config, repo string, cr *provider.ChangeRequest, data commentData, user *provider.User, p provider.Provider,
+) (bool, error) {
+	if user != nil {
+		approvals, err := p.ListApprovals(repo, cr)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"repository": repo, "mr_id": cr.ID}).Warn("Failed to fetch approvals")
+		}
+
+		for _, approval := range approvals {
+			if approval.User.ID == user.ID && !approval.Stale {
+				return false, nil
+			}
+		}
+	}
+
+	// The approve command is a GitLab quick action, the provider approves through its API instead.
+	body := ""
+
+	if config.AddComment {
+		comment, err := renderCommentData(config, data)
+		if err != nil {
+			return false, err
+		}
+
+		body = comment
+	}
+
+	return true, p.Approve(repo, cr, body)
+}
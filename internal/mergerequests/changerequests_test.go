@@ -0,0 +1,189 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/provider"
+)
+
+// fakeProvider is an in-memory provider.Provider.
+type fakeProvider struct {
+	changeRequests []*provider.ChangeRequest
+	statuses       map[int64]*provider.CheckStatus
+	approvals      map[int64][]provider.Approval
+	approved       map[int64]string
+}
+
+func (f *fakeProvider) Name() string { return "fake" }
+
+func (f *fakeProvider) CurrentUser() (*provider.User, error) {
+	return &provider.User{ID: 1, Username: "renoglaab"}, nil
+}
+
+func (f *fakeProvider) ListChangeRequests(string, provider.ListOptions) ([]*provider.ChangeRequest, error) {
+	return f.changeRequests, nil
+}
+
+func (f *fakeProvider) CheckStatus(_ string, cr *provider.ChangeRequest) (*provider.CheckStatus, error) {
+	status, ok := f.statuses[cr.ID]
+	if !ok {
+		return nil, errors.New("API error")
+	}
+
+	return status, nil
+}
+
+func (f *fakeProvider) ListApprovals(_ string, cr *provider.ChangeRequest) ([]provider.Approval, error) {
+	return f.approvals[cr.ID], nil
+}
+
+func (f *fakeProvider) Approve(_ string, cr *provider.ChangeRequest, body string) error {
+	f.approved[cr.ID] = body
+
+	return nil
+}
+
+func (f *fakeProvider) ListComments(string, *provider.ChangeRequest) ([]*provider.Comment, error) {
+	return nil, nil
+}
+
+func (f *fakeProvider) CreateComment(string, *provider.ChangeRequest, string) error { return nil }
+
+func (f *fakeProvider) UpdateComment(string, *provider.ChangeRequest, int64, string) error {
+	return nil
+}
+
+func TestReconcileChangeRequests(t *testing.T) {
+	t.Parallel()
+
+	success := &provider.CheckStatus{State: provider.CheckSuccess, Status: "success"}
+	renoglaab := provider.User{ID: 1, Username: "renoglaab"}
+
+	tests := []struct {
+		name         string
+		cr           *provider.ChangeRequest
+		status       *provider.CheckStatus
+		approvals    []provider.Approval
+		addComment   bool
+		expectBody   string
+		expectSkip   bool
+		expectFilter error
+	}{
+		{
+			name:       "approves with rendered comment",
+			cr:         &provider.ChangeRequest{ID: 1, Title: "Update dependency eslint to v8.2.0", SourceBranch: "renovate/automerge", SHA: "abc"},
+			status:     success,
+			addComment: true,
+			expectBody: "eslint v8.2.0 passed state, author, branch, exclude-labels, mergeability, pipeline",
+		},
+		{
+			name:       "approves without comment",
+			cr:         &provider.ChangeRequest{ID: 1, SourceBranch: "renovate/automerge", SHA: "abc"},
+			status:     success,
+			expectBody: "",
+		},
+		{
+			name:       "already approved at head",
+			cr:         &provider.ChangeRequest{ID: 1, SourceBranch: "renovate/automerge", SHA: "abc"},
+			status:     success,
+			approvals:  []provider.Approval{{User: renoglaab}},
+			expectSkip: true,
+		},
+		{
+			name:      "stale approval is renewed",
+			cr:        &provider.ChangeRequest{ID: 1, SourceBranch: "renovate/automerge", SHA: "abc"},
+			status:    success,
+			approvals: []provider.Approval{{User: renoglaab, Stale: true}, {User: provider.User{ID: 2, Username: "human"}}},
+		},
+		{
+			name:         "branch mismatch",
+			cr:           &provider.ChangeRequest{ID: 1, SourceBranch: "feature", SHA: "abc"},
+			status:       success,
+			expectSkip:   true,
+			expectFilter: errBranchMismatch,
+		},
+		{
+			name:         "excluded label",
+			cr:           &provider.ChangeRequest{ID: 1, SourceBranch: "renovate/automerge", SHA: "abc", Labels: []string{"do-not-merge"}},
+			status:       success,
+			expectSkip:   true,
+			expectFilter: errExcludedLabel,
+		},
+		{
+			name:         "draft",
+			cr:           &provider.ChangeRequest{ID: 1, SourceBranch: "renovate/automerge", SHA: "abc", Draft: true},
+			status:       success,
+			expectSkip:   true,
+			expectFilter: errDraft,
+		},
+		{
+			name:         "no checks",
+			cr:           &provider.ChangeRequest{ID: 1, SourceBranch: "renovate/automerge", SHA: "abc"},
+			status:       &provider.CheckStatus{State: provider.CheckNone},
+			expectSkip:   true,
+			expectFilter: errNoPipeline,
+		},
+		{
+			name:         "checks pending",
+			cr:           &provider.ChangeRequest{ID: 1, SourceBranch: "renovate/automerge", SHA: "abc"},
+			status:       &provider.CheckStatus{State: provider.CheckPending, Status: "pending"},
+			expectSkip:   true,
+			expectFilter: errPipelineRunning,
+		},
+		{
+			name:         "checks failed",
+			cr:           &provider.ChangeRequest{ID: 1, SourceBranch: "renovate/automerge", SHA: "abc"},
+			status:       &provider.CheckStatus{State: provider.CheckFailure, Status: "failure"},
+			expectSkip:   true,
+			expectFilter: errPipelineNotSucceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := config.Config{
+				FilterByAuthorUsername:     true,
+				AuthorUsername:             "renovate-bot",
+				FilterByBranch:             true,
+				AllowedBranchRegexCompiled: regexp.MustCompile(`^renovate/automerge$`),
+				ExcludeLabels:              []string{"do-not-merge"},
+				FilterDraft:                true,
+				FilterBySucceededPipeline:  true,
+				AddComment:                 tt.addComment,
+				Approve:                    "/approve",
+				CommentTemplate: template.Must(template.New("comment").Funcs(template.FuncMap{"join": strings.Join}).Parse(
+					`{{ range .Renovate.Updates }}{{ .Package }} {{ .To }}{{ end }} passed {{ join .Filters ", " }}`,
+				)),
+			}
+
+			p := &fakeProvider{
+				changeRequests: []*provider.ChangeRequest{tt.cr},
+				statuses:       map[int64]*provider.CheckStatus{tt.cr.ID: tt.status},
+				approvals:      map[int64][]provider.Approval{tt.cr.ID: tt.approvals},
+				approved:       map[int64]string{},
+			}
+
+			_, r := evaluateChangeRequest(cfg, "owner/repo", tt.cr, p)
+			if tt.expectFilter != nil {
+				require.NotNil(t, r)
+				require.ErrorIs(t, r.err, tt.expectFilter)
+			}
+
+			ReconcileChangeRequests(cfg, "owner/repo", p)
+
+			body, approved := p.approved[tt.cr.ID]
+			assert.Equal(t, !tt.expectSkip, approved)
+			assert.Equal(t, tt.expectBody, body)
+		})
+	}
+}
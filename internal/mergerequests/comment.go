@@ -0,0 +1,78 @@
+package mergerequests
+
+import (
+	"strings"
+
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/renovate"
+)
+
+// commentData is the data available to the comment template.
+type commentData struct {
+	MergeRequest mergeRequestData
+	Pipeline     *pipelineData
+	Renovate     renovate.Info
+	Filters      []string
+}
+
+type mergeRequestData struct {
+	IID          int64
+	Title        string
+	SourceBranch string
+	TargetBranch string
+	Author       string
+	WebURL       string
+}
+
+type pipelineData struct {
+	ID     int64
+	WebURL string
+	Status string
+}
+
+func newCommentData(c *candidate) commentData {
+	data := commentData{
+		MergeRequest: mergeRequestData{
+			IID:          c.mr.IID,
+			Title:        c.mr.Title,
+			SourceBranch: c.mr.SourceBranch,
+			TargetBranch: c.mr.TargetBranch,
+			WebURL:       c.mr.WebURL,
+		},
+		Renovate: renovate.Parse(c.mr.Title, c.mr.Description),
+		Filters:  c.passedFilters,
+	}
+
+	if c.mr.Author != nil {
+		data.MergeRequest.Author = c.mr.Author.Username
+	}
+
+	if c.pipeline != nil {
+		data.Pipeline = &pipelineData{ID: c.pipeline.ID, WebURL: c.pipeline.WebURL, Status: c.pipeline.Status}
+	}
+
+	return data
+}
+
+// renderComment returns the note body to post for a candidate.
+// The approve command is used as is, the comment is rendered from its template.
+func renderComment(config config.Config, c *candidate) (string, error) {
+	return renderCommentData(config, newCommentData(c))
+}
+
+func renderCommentData(config config.Config, data commentData) (string, error) {
+	if !config.AddComment {
+		return config.Approve, nil
+	}
+
+	if config.CommentTemplate == nil {
+		return config.Comment, nil
+	}
+
+	var comment strings.Builder
+	if err := config.CommentTemplate.Execute(&comment, data); err != nil {
+		return "", err
+	}
+
+	return comment.String(), nil
+}
@@ -0,0 +1,49 @@
+//nolint:lll
+package mergerequests
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestRenderComment(t *testing.T) {
+	t.Parallel()
+
+	c := &candidate{
+		mr: &gitlab.BasicMergeRequest{
+			IID:          3,
+			Title:        "Update dependency eslint to v8.2.0",
+			SourceBranch: "renovate/eslint",
+			Author:       &gitlab.BasicUser{Username: "renovate-bot"},
+		},
+		pipeline:      &gitlab.Pipeline{ID: 100, Status: "success", WebURL: "https://gitlab.com/p/-/pipelines/100"},
+		passedFilters: []string{"branch", "pipeline"},
+	}
+
+	tmpl := template.Must(template.New("comment").Funcs(template.FuncMap{"join": strings.Join}).Parse(
+		`!{{ .MergeRequest.IID }} by {{ .MergeRequest.Author }}: {{ range .Renovate.Updates }}{{ .Package }} {{ .To }}{{ end }}, pipeline #{{ .Pipeline.ID }} {{ .Pipeline.Status }}, passed {{ join .Filters ", " }}`,
+	))
+
+	comment, err := renderComment(config.Config{AddComment: true, CommentTemplate: tmpl}, c)
+	require.NoError(t, err)
+	assert.Equal(t, "!3 by renovate-bot: eslint v8.2.0, pipeline #100 success, passed branch, pipeline", comment)
+
+	comment, err = renderComment(config.Config{AddComment: false, Approve: "/approve", CommentTemplate: tmpl}, c)
+	require.NoError(t, err)
+	assert.Equal(t, "/approve", comment)
+}
+
+func TestRenderCommentError(t *testing.T) {
+	t.Parallel()
+
+	tmpl := template.Must(template.New("comment").Parse(`{{ .Missing }}`))
+
+	_, err := renderComment(config.Config{AddComment: true, CommentTemplate: tmpl}, &candidate{mr: &gitlab.BasicMergeRequest{}})
+	assert.Error(t, err)
+}
@@ -1,12 +1,20 @@
 package mergerequests
 
-import gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+import (
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
 
-func createMergeRequestNote(repo string, mr int64, comment string, client *gitlab.Client) error {
+// createMergeRequestNote adds a note to a merge request and returns its ID.
+func createMergeRequestNote(repo string, mr int64, comment string, client gl.Client) (int64, error) {
 	noteOptions := &gitlab.CreateMergeRequestNoteOptions{
 		Body: gitlab.Ptr(comment),
 	}
-	_, _, err := client.Notes.CreateMergeRequestNote(repo, mr, noteOptions)
 
-	return err
+	note, _, err := client.CreateMergeRequestNote(repo, mr, noteOptions)
+	if err != nil || note == nil {
+		return 0, err
+	}
+
+	return note.ID, nil
 }
@@ -0,0 +1,94 @@
+package mergerequests
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// Reasons for rejecting a merge request that wasn't found by listing the merge requests.
+var (
+	errNotOpen        = errors.New("merge request is not open")
+	errAuthorMismatch = errors.New("merge request is not authored by the configured user")
+	errMissingLabel   = errors.New("merge request is missing a required label")
+	errNotApprovable  = errors.New("merge request did not pass the filters")
+)
+
+// EvaluateProject checks the open merge requests of a project against the filters, without changing anything.
+// It returns the decision trace of every merge request, stopping at the first filter rejecting it.
+func EvaluateProject(config config.Config, repo string, client gl.Client) []*Trace {
+	candidates, rejections := listProjectMergeRequests(config, repo, client)
+
+	traces := make([]*Trace, 0, len(candidates)+len(rejections))
+
+	for _, c := range candidates {
+		traces = append(traces, c.trace)
+	}
+
+	for _, r := range rejections {
+		traces = append(traces, r.trace)
+	}
+
+	slices.SortFunc(traces, func(a, b *Trace) int { return cmp.Compare(a.IID, b.IID) })
+
+	return traces
+}
+
+// EvaluateMergeRequest runs every filter on a single merge request, without changing anything,
+// and returns its decision trace.
+func EvaluateMergeRequest(config config.Config, repo string, mrIID int64, client gl.Client) (*Trace, error) {
+	c, r, err := evaluateMergeRequest(config, repo, mrIID, client, evaluateOptions{all: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if r != nil {
+		return r.trace, nil
+	}
+
+	return c.trace, nil
+}
+
+// ApproveMergeRequest approves a single merge request right away if it passes the filters,
+// even if renoglaab already handled it at its current head. It returns false if it was already approved.
+func ApproveMergeRequest(
+	config config.Config, repo string, mrIID int64, client gl.Client, store *state.Store,
+) (bool, error) {
+	c, r, err := evaluateMergeRequest(config, repo, mrIID, client, evaluateOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	if r != nil {
+		return false, fmt.Errorf("%w: %s: %w", errNotApprovable, r.filter, r.err)
+	}
+
+	// Notes are only trusted if renoglaab posted them, so it doesn't approve without knowing its user.
+	user, _, err := client.CurrentUser()
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch current user: %w", err)
+	}
+
+	return approveCandidate(config, repo, c, user, client, store, true)
+}
+
+// evaluateMergeRequest fetches a merge request and runs the filters on it.
+// The state, author and labels are checked too, as they are otherwise filtered when listing the merge requests.
+func evaluateMergeRequest(
+	config config.Config, repo string, mrIID int64, client gl.Client, opts evaluateOptions,
+) (*candidate, *rejection, error) {
+	detailed, _, err := client.GetMergeRequest(repo, mrIID, &gitlab.GetMergeRequestsOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, r := evaluateFilters(repo, &detailed.BasicMergeRequest, config, client, opts)
+
+	return c, r, nil
+}
@@ -0,0 +1,121 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestEvaluateMergeRequest(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	cfg := config.Config{
+		FilterByAuthorUsername:     true,
+		AuthorUsername:             "renovate-bot",
+		FilterByLabels:             true,
+		Labels:                     []string{"renovate"},
+		FilterByBranch:             true,
+		AllowedBranchRegexCompiled: regexp.MustCompile("^renovate/.*$"),
+	}
+
+	renovate := &gitlab.BasicUser{Username: "renovate-bot"}
+
+	tests := []struct {
+		name           string
+		mr             gitlab.BasicMergeRequest
+		expectRejected string
+		expectPassed   []string
+		expectError    error
+	}{
+		{
+			name:         "Approvable",
+			mr:           gitlab.BasicMergeRequest{State: stateOpen, Author: renovate, Labels: gitlab.Labels{"renovate"}, SourceBranch: "renovate/foo"},
+			expectPassed: []string{filterState, filterAuthor, filterLabels, filterBranch},
+		},
+		{
+			name:           "Merged",
+			mr:             gitlab.BasicMergeRequest{State: "merged", Author: renovate, Labels: gitlab.Labels{"renovate"}, SourceBranch: "renovate/foo"},
+			expectRejected: filterState,
+			expectPassed:   []string{filterAuthor, filterLabels, filterBranch},
+			expectError:    errNotOpen,
+		},
+		{
+			name:           "Other author",
+			mr:             gitlab.BasicMergeRequest{State: stateOpen, Author: &gitlab.BasicUser{Username: "alice"}, Labels: gitlab.Labels{"renovate"}, SourceBranch: "renovate/foo"},
+			expectRejected: filterAuthor,
+			expectPassed:   []string{filterState, filterLabels, filterBranch},
+			expectError:    errAuthorMismatch,
+		},
+		{
+			name:           "Missing label",
+			mr:             gitlab.BasicMergeRequest{State: stateOpen, Author: renovate, SourceBranch: "renovate/foo"},
+			expectRejected: filterLabels,
+			expectPassed:   []string{filterState, filterAuthor, filterBranch},
+			expectError:    errMissingLabel,
+		},
+		{
+			name:           "Branch mismatch and missing label",
+			mr:             gitlab.BasicMergeRequest{State: stateOpen, Author: renovate, SourceBranch: "feature"},
+			expectRejected: filterLabels,
+			expectPassed:   []string{filterState, filterAuthor},
+			expectError:    errMissingLabel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("GetMergeRequest", repo, int64(1), mock.Anything).Return(&gitlab.MergeRequest{BasicMergeRequest: tt.mr}, nil)
+
+			trace, err := EvaluateMergeRequest(cfg, repo, 1, mockClient)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectRejected == "", trace.Approvable)
+			assert.Equal(t, tt.expectRejected, trace.RejectedBy)
+			assert.Equal(t, tt.expectPassed, trace.passed())
+
+			if tt.expectError != nil {
+				assert.Contains(t, trace.Reason, tt.expectError.Error())
+			}
+		})
+	}
+}
+
+func TestApproveMergeRequestCommand(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	cfg := config.Config{FilterByBranch: true, AllowedBranchRegexCompiled: regexp.MustCompile("^renovate/.*$"), Approve: "/approve"}
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("GetMergeRequest", repo, int64(2), mock.Anything).Return(&gitlab.MergeRequest{BasicMergeRequest: gitlab.BasicMergeRequest{IID: 2, State: stateOpen, SourceBranch: "feature"}}, nil)
+
+	_, err := ApproveMergeRequest(cfg, repo, 2, mockClient, newMemoryStore(t))
+	require.ErrorIs(t, err, errNotApprovable)
+	require.ErrorIs(t, err, errBranchMismatch)
+
+	// An earlier approval note at the same commit doesn't stop a forced approval.
+	mr := gitlab.BasicMergeRequest{IID: 1, SHA: "abc", State: stateOpen, SourceBranch: "renovate/foo"}
+	notes := []*gitlab.Note{{ID: 7, Author: gitlab.NoteAuthor{ID: 42}, Body: withNoteMarker("/approve", noteKindApproval, "abc")}}
+
+	mockClient.On("GetMergeRequest", repo, int64(1), mock.Anything).Return(&gitlab.MergeRequest{BasicMergeRequest: mr}, nil)
+	mockClient.On("CurrentUser").Return(user, nil)
+	mockClient.On("GetMergeRequestApprovals", repo, int64(1)).Return(&gitlab.MergeRequestApprovals{}, nil)
+	mockClient.On("ListMergeRequestNotes", repo, int64(1), mock.Anything).Return(notes, nil)
+	mockClient.On("CreateMergeRequestNote", repo, int64(1), mock.Anything).Return(&gitlab.Note{}, nil)
+
+	approved, err := ApproveMergeRequest(cfg, repo, 1, mockClient, newMemoryStore(t))
+	require.NoError(t, err)
+	assert.True(t, approved)
+	mockClient.AssertCalled(t, "CreateMergeRequestNote", repo, int64(1), mock.Anything)
+}
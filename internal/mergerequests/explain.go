@@ -0,0 +1,72 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// explanationBody returns the note explaining why a merge request was not approved.
+func explanationBody(r *rejection) string {
+	body := fmt.Sprintf(
+		":no_entry: renoglaab did not approve this merge request.\n\n**Blocked by:** `%s`: %s",
+		r.filter, r.err,
+	)
+
+	return withNoteMarker(body, noteKindExplanation, r.mr.SHA)
+}
+
+// explainRejection adds a note with the rejection reason, or updates the existing one if the reason changed.
+func explainRejection(repo string, r *rejection, user *gitlab.User, client gl.Client, store *state.Store) {
+	fields := logrus.Fields{"repository": repo, "mrID": r.mr.IID, "filter": r.filter}
+
+	note, err := postedNote(repo, r.mr.IID, noteKindExplanation, user, client, store)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to list merge request notes")
+
+		return
+	}
+
+	body := explanationBody(r)
+
+	if note != nil && note.Digest == noteDigest(body) {
+		return
+	}
+
+	if err := writeNote(repo, r.mr.IID, noteKindExplanation, r.mr.SHA, body, note, client, store); err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to write rejection explanation")
+
+		return
+	}
+
+	logrus.WithFields(fields).Debug("Explained rejection")
+}
+
+// clearExplanation removes a previously posted rejection explanation.
+func clearExplanation(repo string, mr *gitlab.BasicMergeRequest, user *gitlab.User, client gl.Client, store *state.Store) {
+	fields := logrus.Fields{"repository": repo, "mrID": mr.IID}
+
+	note, err := postedNote(repo, mr.IID, noteKindExplanation, user, client, store)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to list merge request notes")
+
+		return
+	}
+
+	if note == nil {
+		return
+	}
+
+	if _, err := client.DeleteMergeRequestNote(repo, mr.IID, note.ID); err != nil && !errors.Is(err, gitlab.ErrNotFound) {
+		logrus.WithError(err).WithFields(fields).Error("Failed to remove rejection explanation")
+
+		return
+	}
+
+	forgetNote(repo, mr.IID, noteKindExplanation, store)
+	logrus.WithFields(fields).Debug("Removed rejection explanation")
+}
@@ -0,0 +1,159 @@
+//nolint:lll,funlen
+package mergerequests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestExplainRejection(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "abc"}
+	r := &rejection{mr: mr, filter: filterPipeline, err: fmt.Errorf("%w: pipeline #100 is failed", errPipelineNotSucceeded), reportable: true}
+
+	tests := []struct {
+		name         string
+		notes        []*gitlab.Note
+		expectCreate int
+		expectUpdate int
+	}{
+		{
+			name:         "No explanation yet",
+			expectCreate: 1,
+		},
+		{
+			name:  "Explanation up to date",
+			notes: []*gitlab.Note{{ID: 7, Body: explanationBody(r), Author: gitlab.NoteAuthor{ID: 42}}},
+		},
+		{
+			name:         "Reason changed",
+			notes:        []*gitlab.Note{{ID: 7, Body: withNoteMarker("old reason", noteKindExplanation, "abc"), Author: gitlab.NoteAuthor{ID: 42}}},
+			expectUpdate: 1,
+		},
+		{
+			name:         "Approval note is not an explanation",
+			notes:        []*gitlab.Note{{ID: 7, Body: withNoteMarker("/approve", noteKindApproval, "abc"), Author: gitlab.NoteAuthor{ID: 42}}},
+			expectCreate: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return(tt.notes, nil)
+			mockClient.On("CreateMergeRequestNote", repo, mr.IID, mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+			mockClient.On("UpdateMergeRequestNote", repo, mr.IID, int64(7), mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+
+			explainRejection(repo, r, user, mockClient, newMemoryStore(t))
+
+			mockClient.AssertNumberOfCalls(t, "CreateMergeRequestNote", tt.expectCreate)
+			mockClient.AssertNumberOfCalls(t, "UpdateMergeRequestNote", tt.expectUpdate)
+		})
+	}
+}
+
+func TestExplainRejectionState(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "abc"}
+	r := &rejection{mr: mr, filter: filterPipeline, err: fmt.Errorf("%w: pipeline #100 is failed", errPipelineNotSucceeded), reportable: true}
+	store := newMemoryStore(t)
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return([]*gitlab.Note{}, nil).Once()
+	mockClient.On("CreateMergeRequestNote", repo, mr.IID, mock.Anything).Return(&gitlab.Note{ID: 7}, nil).Once()
+
+	explainRejection(repo, r, user, mockClient, store)
+	assert.Equal(t, state.Note{ID: 7, SHA: "abc", Digest: noteDigest(explanationBody(r))}, store.MergeRequest(repo, mr.IID).Notes[string(noteKindExplanation)])
+
+	// The recorded note is up to date, so the notes aren't listed again.
+	explainRejection(repo, r, user, mockClient, store)
+	mockClient.AssertExpectations(t)
+
+	// A note deleted by someone else is posted again.
+	r.err = fmt.Errorf("%w: pipeline #101 is failed", errPipelineNotSucceeded)
+
+	mockClient.On("UpdateMergeRequestNote", repo, mr.IID, int64(7), mock.Anything).Return(nil, gitlab.ErrNotFound).Once()
+	mockClient.On("CreateMergeRequestNote", repo, mr.IID, mock.Anything).Return(&gitlab.Note{ID: 8}, nil).Once()
+
+	explainRejection(repo, r, user, mockClient, store)
+	mockClient.AssertExpectations(t)
+	assert.Equal(t, int64(8), store.MergeRequest(repo, mr.IID).Notes[string(noteKindExplanation)].ID)
+}
+
+func TestClearExplanation(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "abc"}
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return([]*gitlab.Note{
+		{ID: 7, Body: withNoteMarker("blocked", noteKindExplanation, "abc"), Author: gitlab.NoteAuthor{ID: 42}},
+	}, nil)
+	mockClient.On("DeleteMergeRequestNote", repo, mr.IID, int64(7)).Return(nil).Once()
+
+	clearExplanation(repo, mr, user, mockClient, newMemoryStore(t))
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestReportRejection(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	cfg := config.Config{ExplainRejections: true}
+
+	tests := []struct {
+		name         string
+		err          error
+		expectCreate int
+	}{
+		{
+			name:         "Pipeline failed",
+			err:          fmt.Errorf("%w: pipeline #100 is failed", errPipelineNotSucceeded),
+			expectCreate: 1,
+		},
+		{
+			name: "Pipeline running",
+			err:  fmt.Errorf("%w: pipeline #100 is running", errPipelineRunning),
+		},
+		{
+			name: "No pipeline yet",
+			err:  errNoPipeline,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "abc"}
+			r := &rejection{mr: mr, filter: filterPipeline, err: tt.err}
+			pipelineFilter{}.classify(r)
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return([]*gitlab.Note{}, nil).Maybe()
+			mockClient.On("CreateMergeRequestNote", repo, mr.IID, mock.Anything).Return(&gitlab.Note{ID: 7}, nil).Maybe()
+
+			reportRejection(cfg, repo, r, user, mockClient, newMemoryStore(t))
+
+			mockClient.AssertNumberOfCalls(t, "CreateMergeRequestNote", tt.expectCreate)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package mergerequests
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/provider"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+var errUnknownFilter = errors.New("unknown filter")
+
+// Filter decides whether a merge request may be approved.
+type Filter interface {
+	// Name identifies the filter in traces, metrics, comment templates and FILTER_ORDER.
+	Name() string
+	// Cost returns the group the filter is checked in. Cheaper groups are checked first.
+	Cost() FilterCost
+	// Enabled reports whether the configuration turns the filter on.
+	Enabled(config config.Config) bool
+	// Evaluate checks the merge request of the context and returns the verdict.
+	Evaluate(fc *FilterContext) Verdict
+}
+
+// FilterCost groups the filters by how expensive they are to check.
+type FilterCost int
+
+const (
+	// CostListing filters are applied by GitLab when listing the merge requests.
+	CostListing FilterCost = iota
+	// CostLocal filters only use the fields of the listed merge request.
+	CostLocal
+	// CostAPI filters make further API calls.
+	CostAPI
+)
+
+// FilterContext is what a filter checks: a merge request of a repository, along with the data
+// gathered by earlier filters.
+type FilterContext struct {
+	Config config.Config
+	Repo   string
+	MR     *gitlab.BasicMergeRequest
+	Client gl.Client
+	// Provider and ChangeRequest are set instead of Client for a change request on another platform,
+	// MR then holds its fields. Only the filters every provider supports can be enabled there.
+	Provider      provider.Provider
+	ChangeRequest *provider.ChangeRequest
+	// Listed is set if the merge request was found by listing, so the listing filters were already applied.
+	Listed bool
+	// Pipeline is the pipeline checked by the pipeline filter, if one was found.
+	Pipeline *gitlab.Pipeline
+	// PolicyInput is the input the policy filter evaluated POLICY against.
+	PolicyInput *PolicyInput
+	// OPAInput is the input document the opa filter sent to OPA.
+	OPAInput *OPAInput
+}
+
+// listingFilter is implemented by filters GitLab applies when listing the merge requests.
+type listingFilter interface {
+	listOptions(config config.Config, opts *gitlab.ListProjectMergeRequestsOptions)
+}
+
+// classifyingFilter is implemented by filters whose rejections are reported on the merge request
+// or lead to further actions, like retrying a pipeline.
+type classifyingFilter interface {
+	classify(r *rejection)
+}
+
+// builtinFilters are the filters renoglaab comes with, in their default order.
+var builtinFilters = []Filter{
+	stateFilter{},
+	authorFilter{},
+	labelsFilter{},
+	branchFilter{},
+	excludeLabelsFilter{},
+	mergeabilityFilter{},
+	holdFilter{},
+	rebaseFilter{},
+	pipelineFilter{},
+	policyFilter{},
+	opaFilter{},
+}
+
+// FilterNames returns the names of the built-in filters.
+func FilterNames() []string {
+	names := make([]string, 0, len(builtinFilters))
+	for _, f := range builtinFilters {
+		names = append(names, f.Name())
+	}
+
+	return names
+}
+
+// ValidateFilterOrder checks that FILTER_ORDER only names known filters.
+func ValidateFilterOrder(order []string) error {
+	names := FilterNames()
+
+	var problems []error
+
+	for _, name := range order {
+		if !slices.Contains(names, name) {
+			problems = append(problems, fmt.Errorf("FILTER_ORDER: %w %q, use one of %s",
+				errUnknownFilter, name, strings.Join(names, ", ")))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// orderedFilters returns the filters in the order they are checked: grouped by cost, cheapest first,
+// and within a group in the configured order, followed by the filters the order doesn't name.
+func orderedFilters(order []string) []Filter {
+	filters := slices.Clone(builtinFilters)
+
+	position := func(f Filter) int {
+		if i := slices.Index(order, f.Name()); i >= 0 {
+			return i
+		}
+
+		return len(order)
+	}
+
+	slices.SortStableFunc(filters, func(a, b Filter) int {
+		if a.Cost() != b.Cost() {
+			return cmp.Compare(a.Cost(), b.Cost())
+		}
+
+		return cmp.Compare(position(a), position(b))
+	})
+
+	return filters
+}
+
+// passed returns a passing verdict.
+func passed(evidence Evidence) Verdict {
+	return Verdict{Status: VerdictPassed, Evidence: evidence}
+}
+
+// failed returns a failing verdict.
+func failed(err error, evidence Evidence) Verdict {
+	return Verdict{Status: VerdictFailed, Reason: err.Error(), Err: err, Evidence: evidence}
+}
+
+// stateFilter only lets open merge requests pass.
+type stateFilter struct{}
+
+func (stateFilter) Name() string               { return filterState }
+func (stateFilter) Cost() FilterCost           { return CostListing }
+func (stateFilter) Enabled(config.Config) bool { return true }
+
+func (stateFilter) listOptions(_ config.Config, opts *gitlab.ListProjectMergeRequestsOptions) {
+	opts.State = gitlab.Ptr(stateOpen)
+}
+
+func (stateFilter) Evaluate(fc *FilterContext) Verdict {
+	evidence := Evidence{"state": fc.MR.State}
+	if fc.MR.State != stateOpen {
+		return failed(fmt.Errorf("%w: %s", errNotOpen, fc.MR.State), evidence)
+	}
+
+	return passed(evidence)
+}
+
+// authorFilter only lets merge requests of the configured author pass.
+type authorFilter struct{}
+
+func (authorFilter) Name() string     { return filterAuthor }
+func (authorFilter) Cost() FilterCost { return CostListing }
+
+func (authorFilter) Enabled(config config.Config) bool {
+	return config.FilterByAuthorUsername
+}
+
+func (authorFilter) listOptions(config config.Config, opts *gitlab.ListProjectMergeRequestsOptions) {
+	opts.AuthorUsername = &config.AuthorUsername
+}
+
+func (authorFilter) Evaluate(fc *FilterContext) Verdict {
+	if fc.MR.Author == nil || fc.MR.Author.Username != fc.Config.AuthorUsername {
+		return failed(errAuthorMismatch, nil)
+	}
+
+	return passed(Evidence{"author": fc.MR.Author.Username})
+}
+
+// labelsFilter only lets merge requests with every configured label pass.
+type labelsFilter struct{}
+
+func (labelsFilter) Name() string     { return filterLabels }
+func (labelsFilter) Cost() FilterCost { return CostListing }
+
+func (labelsFilter) Enabled(config config.Config) bool {
+	return config.FilterByLabels
+}
+
+func (labelsFilter) listOptions(config config.Config, opts *gitlab.ListProjectMergeRequestsOptions) {
+	labels := gitlab.LabelOptions(config.Labels)
+	opts.Labels = &labels
+}
+
+func (labelsFilter) Evaluate(fc *FilterContext) Verdict {
+	evidence := Evidence{"labels": fc.MR.Labels}
+
+	for _, label := range fc.Config.Labels {
+		if !slices.Contains(fc.MR.Labels, label) {
+			return failed(fmt.Errorf("%w: %s", errMissingLabel, label), evidence)
+		}
+	}
+
+	return passed(evidence)
+}
+
+// branchFilter only lets merge requests from branches matching the allowed regex pass.
+type branchFilter struct{}
+
+func (branchFilter) Name() string     { return filterBranch }
+func (branchFilter) Cost() FilterCost { return CostLocal }
+
+func (branchFilter) Enabled(config config.Config) bool {
+	return config.FilterByBranch
+}
+
+func (branchFilter) Evaluate(fc *FilterContext) Verdict {
+	regex := fc.Config.AllowedBranchRegexCompiled
+
+	evidence := Evidence{"branch": fc.MR.SourceBranch, "regex": regex.String()}
+	if !regex.MatchString(fc.MR.SourceBranch) {
+		return failed(errBranchMismatch, evidence)
+	}
+
+	return passed(evidence)
+}
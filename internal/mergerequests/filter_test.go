@@ -0,0 +1,82 @@
+//nolint:lll
+package mergerequests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func filterNames(filters []Filter) []string {
+	names := make([]string, 0, len(filters))
+	for _, f := range filters {
+		names = append(names, f.Name())
+	}
+
+	return names
+}
+
+func TestOrderedFilters(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		order  []string
+		expect []string
+	}{
+		{
+			name:   "Default order",
+			expect: FilterNames(),
+		},
+		{
+			name:   "Configured order within each cost group",
+			order:  []string{"pipeline", "mergeability", "hold"},
+			expect: []string{"state", "author", "labels", "mergeability", "branch", "exclude-labels", "pipeline", "hold", "rebase", "policy", "opa"},
+		},
+		{
+			name:   "Expensive filters can't run before cheap ones",
+			order:  []string{"pipeline", "labels", "branch"},
+			expect: []string{"labels", "state", "author", "branch", "exclude-labels", "mergeability", "pipeline", "hold", "rebase", "policy", "opa"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expect, filterNames(orderedFilters(tt.order)))
+		})
+	}
+}
+
+func TestValidateFilterOrder(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, ValidateFilterOrder([]string{"pipeline", "hold"}))
+
+	err := ValidateFilterOrder([]string{"pipeline", "hodl"})
+	require.ErrorIs(t, err, errUnknownFilter)
+	assert.ErrorContains(t, err, `FILTER_ORDER: unknown filter "hodl", use one of state, author`)
+}
+
+func TestListingFilters(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{FilterByAuthorUsername: true, AuthorUsername: "renovate-bot", Labels: []string{"renovate"}}
+
+	opts := &gitlab.ListProjectMergeRequestsOptions{}
+
+	for _, f := range builtinFilters {
+		if lf, ok := f.(listingFilter); ok && f.Enabled(cfg) {
+			assert.Equal(t, CostListing, f.Cost(), f.Name())
+			lf.listOptions(cfg, opts)
+		}
+	}
+
+	assert.Equal(t, stateOpen, *opts.State)
+	assert.Equal(t, "renovate-bot", *opts.AuthorUsername)
+	assert.Nil(t, opts.Labels, "labels are only filtered if FILTER_BY_LABELS is set")
+}
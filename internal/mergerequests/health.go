@@ -0,0 +1,277 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const (
+	stateMerged           = "merged"
+	pipelineStatusSkipped = "skipped"
+)
+
+// checkMergedHealth watches the target branch after the Renovate merge requests merged within the check window,
+// whether renoglaab, Renovate's own automerge or a person merged them. They are picked by the same author, label
+// and branch settings as the open ones. If the first pipeline containing a merge failed, the merge is reverted
+// through a new MR and the project is blocked.
+func checkMergedHealth(config config.Config, repo string, user *gitlab.User, client gl.Client, store *state.Store) {
+	// Without the current user there is no telling which failed merges were already handled.
+	if user == nil {
+		return
+	}
+
+	options := &gitlab.ListProjectMergeRequestsOptions{
+		UpdatedAfter: gitlab.Ptr(time.Now().Add(-config.PostMergeCheckWindow)),
+	}
+
+	for _, f := range builtinFilters {
+		if lf, ok := f.(listingFilter); ok && f.Enabled(config) {
+			lf.listOptions(config, options)
+		}
+	}
+
+	options.State = gitlab.Ptr(stateMerged)
+
+	mrs, _, err := client.ListProjectMergeRequests(repo, options)
+	if err != nil {
+		logrus.WithError(err).WithField("repository", repo).Error("Failed to list merged MRs")
+
+		return
+	}
+
+	for _, mr := range mrs {
+		if config.FilterByBranch && !config.AllowedBranchRegexCompiled.MatchString(mr.SourceBranch) {
+			continue
+		}
+
+		checkMergedMergeRequest(config, repo, mr, user, client, store)
+	}
+}
+
+// checkMergedMergeRequest reverts a merge request whose merge broke the target branch.
+// Each broken merge is handled once, which is recorded in the state and a note on the merged MR.
+func checkMergedMergeRequest(
+	config config.Config, repo string, mr *gitlab.BasicMergeRequest, user *gitlab.User, client gl.Client, store *state.Store,
+) {
+	sha := mergeCommitSHA(mr)
+	fields := logrus.Fields{"repository": repo, "mrID": mr.IID, "branch": mr.TargetBranch, "sha": sha}
+
+	pipeline, err := firstPipelineAfterMerge(repo, mr, sha, client)
+	if err != nil {
+		if errors.Is(err, errNoPipeline) {
+			logrus.WithFields(fields).Debug("No pipeline after merge yet")
+		} else {
+			logrus.WithError(err).WithFields(fields).Error("Failed to list pipelines after merge")
+		}
+
+		return
+	}
+
+	if pipeline.Status != jobStatusFailed {
+		logrus.WithFields(fields).WithField("pipeline_status", pipeline.Status).Debug("Pipeline after merge did not fail")
+
+		return
+	}
+
+	note, err := postedNote(repo, mr.IID, noteKindHealth, user, client, store)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to list merge request notes")
+
+		return
+	}
+
+	if note != nil && note.SHA == sha {
+		logrus.WithFields(fields).Debug("Failed merge already handled")
+
+		return
+	}
+
+	logrus.WithFields(fields).WithField("pipeline_id", pipeline.ID).Warn("Pipeline failed after merging MR")
+
+	// The project is blocked before the revert is attempted, so nothing else is merged even if the revert fails.
+	_, _, err = client.UpdateMergeRequest(repo, mr.IID, &gitlab.UpdateMergeRequestOptions{
+		AddLabels: &gitlab.LabelOptions{config.BlockLabel},
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to block project")
+	}
+
+	// Without the note the revert is tried again on the next run.
+	revert, err := createRevertMergeRequest(config, repo, mr, sha, pipeline, client)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to create revert merge request")
+
+		return
+	}
+
+	logrus.WithFields(fields).WithField("revert_mr_id", revert.IID).Info("Created revert MR")
+
+	body := fmt.Sprintf(
+		":rotating_light: Pipeline #%d on `%s` failed after this merge request was merged. renoglaab opened !%d to revert it."+
+			"\n\nAutomatic approvals and merges are stopped in this project until the `%s` label is removed from this merge request.",
+		pipeline.ID, mr.TargetBranch, revert.IID, config.BlockLabel,
+	)
+
+	body = withNoteMarker(body, noteKindHealth, sha)
+
+	if err := writeNote(repo, mr.IID, noteKindHealth, sha, body, note, client, store); err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to record failed merge")
+	}
+}
+
+// mergeCommitSHA returns the commit a merge request added to its target branch.
+func mergeCommitSHA(mr *gitlab.BasicMergeRequest) string {
+	switch {
+	case mr.MergeCommitSHA != "":
+		return mr.MergeCommitSHA
+	case mr.SquashCommitSHA != "":
+		return mr.SquashCommitSHA
+	default:
+		// Fast-forward merges put the head commit on the target branch.
+		return mr.SHA
+	}
+}
+
+// firstPipelineAfterMerge returns the first target branch pipeline containing the merge commit.
+// Pipelines for the merge commit itself are preferred. If there are none, e.g. because another
+// commit landed right after it, the first later pipeline of the branch is used.
+// Canceled and skipped pipelines don't tell anything about the merge and are ignored.
+func firstPipelineAfterMerge(
+	repo string, mr *gitlab.BasicMergeRequest, sha string, client gl.Client,
+) (*gitlab.PipelineInfo, error) {
+	options := &gitlab.ListProjectPipelinesOptions{
+		Ref:     gitlab.Ptr(mr.TargetBranch),
+		SHA:     gitlab.Ptr(sha),
+		OrderBy: gitlab.Ptr("id"),
+		Sort:    gitlab.Ptr("asc"),
+	}
+
+	pipelines, _, err := client.ListProjectPipelines(repo, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pipelines) == 0 && mr.MergedAt != nil {
+		options.SHA = nil
+		options.CreatedAfter = mr.MergedAt
+
+		pipelines, _, err = client.ListProjectPipelines(repo, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pipeline := range pipelines {
+		if pipeline.Status != jobStatusCanceled && pipeline.Status != pipelineStatusSkipped {
+			return pipeline, nil
+		}
+	}
+
+	return nil, errNoPipeline
+}
+
+// createRevertMergeRequest reverts the merge commit on a new branch and opens a merge request for it.
+// A revert MR or branch left behind by an earlier run, e.g. because its note couldn't be written, is reused.
+// If the revert or the merge request fails, the branch is deleted again so the next run can start over.
+func createRevertMergeRequest(
+	config config.Config, repo string, mr *gitlab.BasicMergeRequest, sha string,
+	pipeline *gitlab.PipelineInfo, client gl.Client,
+) (*gitlab.BasicMergeRequest, error) {
+	branch := fmt.Sprintf("renoglaab/revert-%d", mr.IID)
+
+	open, _, err := client.ListProjectMergeRequests(repo, &gitlab.ListProjectMergeRequestsOptions{
+		State:        gitlab.Ptr(stateOpen),
+		SourceBranch: gitlab.Ptr(branch),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merge requests of branch %s: %w", branch, err)
+	}
+
+	if len(open) > 0 {
+		return open[0], nil
+	}
+
+	reverted, err := prepareRevertBranch(repo, branch, mr.TargetBranch, sha, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if !reverted {
+		if _, _, err := client.RevertCommit(repo, sha, &gitlab.RevertCommitOptions{Branch: gitlab.Ptr(branch)}); err != nil {
+			return nil, deleteRevertBranch(repo, branch, fmt.Errorf("failed to revert %s: %w", sha, err), client)
+		}
+	}
+
+	labels := gitlab.LabelOptions(config.RevertLabels)
+
+	revert, _, err := client.CreateMergeRequest(repo, &gitlab.CreateMergeRequestOptions{
+		Title: gitlab.Ptr(fmt.Sprintf("Revert %q", mr.Title)),
+		Description: gitlab.Ptr(fmt.Sprintf(
+			"Reverts !%d because pipeline #%d on `%s` failed after it was merged.",
+			mr.IID, pipeline.ID, mr.TargetBranch,
+		)),
+		SourceBranch:       gitlab.Ptr(branch),
+		TargetBranch:       gitlab.Ptr(mr.TargetBranch),
+		Labels:             &labels,
+		RemoveSourceBranch: gitlab.Ptr(true),
+	})
+	if err != nil {
+		return nil, deleteRevertBranch(repo, branch, fmt.Errorf("failed to create merge request: %w", err), client)
+	}
+
+	return &revert.BasicMergeRequest, nil
+}
+
+// prepareRevertBranch creates the revert branch from the target branch, unless it is left over from an earlier run.
+// It reports whether the branch already holds the revert commit, whose message names the reverted commit.
+func prepareRevertBranch(repo, branch, target, sha string, client gl.Client) (bool, error) {
+	existing, _, err := client.GetBranch(repo, branch)
+
+	switch {
+	case err == nil:
+		return existing.Commit != nil && strings.Contains(existing.Commit.Message, "This reverts commit "+sha), nil
+	case !errors.Is(err, gitlab.ErrNotFound):
+		return false, fmt.Errorf("failed to get branch %s: %w", branch, err)
+	}
+
+	if _, _, err := client.CreateBranch(repo, &gitlab.CreateBranchOptions{
+		Branch: gitlab.Ptr(branch),
+		Ref:    gitlab.Ptr(target),
+	}); err != nil {
+		return false, fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+
+	return false, nil
+}
+
+// deleteRevertBranch deletes the branch of a failed revert and returns the error that made it fail.
+func deleteRevertBranch(repo, branch string, err error, client gl.Client) error {
+	if _, deleteErr := client.DeleteBranch(repo, branch); deleteErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to delete branch %s: %w", branch, deleteErr))
+	}
+
+	return err
+}
+
+// projectBlocked reports whether a merged MR still carries the block label,
+// i.e. nobody cleared the block after a merge broke the target branch.
+func projectBlocked(config config.Config, repo string, client gl.Client) (bool, error) {
+	mrs, _, err := client.ListProjectMergeRequests(repo, &gitlab.ListProjectMergeRequestsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 1},
+		State:       gitlab.Ptr(stateMerged),
+		Labels:      &gitlab.LabelOptions{config.BlockLabel},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(mrs) > 0, nil
+}
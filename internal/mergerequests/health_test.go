@@ -0,0 +1,235 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestCheckMergedHealth(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	cfg := config.Config{
+		PostMergeCheckWindow: 24 * time.Hour, RevertLabels: []string{"renoglaab-revert"}, BlockLabel: "renoglaab-blocked",
+		FilterByAuthorUsername: true, AuthorUsername: "renovate-bot",
+		FilterByBranch: true, AllowedBranchRegexCompiled: regexp.MustCompile("^renovate/"),
+	}
+	mergedAt := time.Now().Add(-time.Hour)
+
+	merged := func(mergedBy int64) *gitlab.BasicMergeRequest {
+		return &gitlab.BasicMergeRequest{
+			IID: 1, Title: "Update dependency foo to v1.2.4", SourceBranch: "renovate/foo-1.x", TargetBranch: "main",
+			SHA: "head", MergeCommitSHA: "merge", MergedAt: &mergedAt, MergedBy: &gitlab.BasicUser{ID: mergedBy},
+		}
+	}
+
+	otherBranch := merged(42)
+	otherBranch.SourceBranch = "feature/foo"
+
+	tests := []struct {
+		name            string
+		mr              *gitlab.BasicMergeRequest
+		shaPipelines    []*gitlab.PipelineInfo
+		laterPipelines  []*gitlab.PipelineInfo
+		notes           []*gitlab.Note
+		openReverts     []*gitlab.BasicMergeRequest
+		revertBranch    *gitlab.Branch
+		revertErr       error
+		expectRevert    bool
+		expectBranch    bool
+		expectCommit    bool
+		expectBlock     bool
+		expectNote      bool
+		expectListNotes bool
+	}{
+		{
+			name:         "Pipeline succeeded",
+			mr:           merged(42),
+			shaPipelines: []*gitlab.PipelineInfo{{ID: 10, Status: "success"}},
+		},
+		{
+			name:         "Pipeline still running",
+			mr:           merged(42),
+			shaPipelines: []*gitlab.PipelineInfo{{ID: 10, Status: "running"}},
+		},
+		{
+			name:            "Merged by someone else",
+			mr:              merged(7),
+			shaPipelines:    []*gitlab.PipelineInfo{{ID: 10, Status: "failed"}},
+			expectListNotes: true,
+			expectRevert:    true,
+			expectBranch:    true,
+			expectCommit:    true,
+			expectBlock:     true,
+			expectNote:      true,
+		},
+		{
+			name: "Not a Renovate branch",
+			mr:   otherBranch,
+		},
+		{
+			name:            "Pipeline failed",
+			mr:              merged(42),
+			shaPipelines:    []*gitlab.PipelineInfo{{ID: 9, Status: "canceled"}, {ID: 10, Status: "failed"}},
+			expectListNotes: true,
+			expectRevert:    true,
+			expectBranch:    true,
+			expectCommit:    true,
+			expectBlock:     true,
+			expectNote:      true,
+		},
+		{
+			name:            "Later pipeline failed",
+			mr:              merged(42),
+			laterPipelines:  []*gitlab.PipelineInfo{{ID: 11, Status: "failed"}},
+			expectListNotes: true,
+			expectRevert:    true,
+			expectBranch:    true,
+			expectCommit:    true,
+			expectBlock:     true,
+			expectNote:      true,
+		},
+		{
+			name:            "Failure already handled",
+			mr:              merged(42),
+			shaPipelines:    []*gitlab.PipelineInfo{{ID: 10, Status: "failed"}},
+			notes:           []*gitlab.Note{{ID: 3, Body: withNoteMarker("reverted", noteKindHealth, "merge"), Author: gitlab.NoteAuthor{ID: 42}}},
+			expectListNotes: true,
+		},
+		{
+			name:            "Revert fails, the project is blocked and the revert tried again",
+			mr:              merged(42),
+			shaPipelines:    []*gitlab.PipelineInfo{{ID: 10, Status: "failed"}},
+			revertErr:       errors.New("conflict"),
+			expectListNotes: true,
+			expectBranch:    true,
+			expectCommit:    true,
+			expectBlock:     true,
+		},
+		{
+			name:            "Revert MR left over from an earlier run",
+			mr:              merged(42),
+			shaPipelines:    []*gitlab.PipelineInfo{{ID: 10, Status: "failed"}},
+			openReverts:     []*gitlab.BasicMergeRequest{{IID: 2}},
+			expectListNotes: true,
+			expectBlock:     true,
+			expectNote:      true,
+		},
+		{
+			name:            "Revert branch left over from an earlier run",
+			mr:              merged(42),
+			shaPipelines:    []*gitlab.PipelineInfo{{ID: 10, Status: "failed"}},
+			revertBranch:    &gitlab.Branch{Name: "renoglaab/revert-1", Commit: &gitlab.Commit{Message: "Revert \"Update dependency foo\"\n\nThis reverts commit merge"}},
+			expectListNotes: true,
+			expectRevert:    true,
+			expectBlock:     true,
+			expectNote:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("ListProjectMergeRequests", repo, mock.MatchedBy(func(opts *gitlab.ListProjectMergeRequestsOptions) bool {
+				return *opts.State == stateMerged && *opts.AuthorUsername == "renovate-bot"
+			})).Return([]*gitlab.BasicMergeRequest{tt.mr}, nil)
+			mockClient.On("ListProjectPipelines", repo, mock.MatchedBy(func(opts *gitlab.ListProjectPipelinesOptions) bool {
+				return opts.SHA != nil
+			})).Return(tt.shaPipelines, nil).Maybe()
+			mockClient.On("ListProjectPipelines", repo, mock.MatchedBy(func(opts *gitlab.ListProjectPipelinesOptions) bool {
+				return opts.SHA == nil && opts.CreatedAfter.Equal(mergedAt)
+			})).Return(tt.laterPipelines, nil).Maybe()
+			mockClient.On("ListMergeRequestNotes", repo, int64(1), mock.Anything).Return(tt.notes, nil).Maybe()
+			mockClient.On("ListProjectMergeRequests", repo, mock.MatchedBy(func(opts *gitlab.ListProjectMergeRequestsOptions) bool {
+				return *opts.State == stateOpen && *opts.SourceBranch == "renoglaab/revert-1"
+			})).Return(tt.openReverts, nil).Maybe()
+
+			branchErr := error(gitlab.ErrNotFound)
+			if tt.revertBranch != nil {
+				branchErr = nil
+			}
+
+			mockClient.On("GetBranch", repo, "renoglaab/revert-1").Return(tt.revertBranch, branchErr).Maybe()
+			mockClient.On("CreateBranch", repo, mock.Anything).Return(&gitlab.Branch{}, nil).Maybe()
+			mockClient.On("RevertCommit", repo, "merge", mock.Anything).Return(&gitlab.Commit{}, tt.revertErr).Maybe()
+			mockClient.On("DeleteBranch", repo, "renoglaab/revert-1").Return(nil).Maybe()
+			mockClient.On("CreateMergeRequest", repo, mock.Anything).Return(&gitlab.MergeRequest{BasicMergeRequest: gitlab.BasicMergeRequest{IID: 2}}, nil).Maybe()
+			mockClient.On("UpdateMergeRequest", repo, int64(1), mock.Anything).Return(&gitlab.MergeRequest{}, nil).Maybe()
+			mockClient.On("CreateMergeRequestNote", repo, int64(1), mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+
+			checkMergedHealth(cfg, repo, user, mockClient, newMemoryStore(t))
+
+			if tt.expectListNotes {
+				mockClient.AssertNumberOfCalls(t, "ListMergeRequestNotes", 1)
+			} else {
+				mockClient.AssertNotCalled(t, "ListMergeRequestNotes", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			if tt.expectRevert {
+				mockClient.AssertCalled(t, "CreateMergeRequest", repo, mock.MatchedBy(func(opts *gitlab.CreateMergeRequestOptions) bool {
+					return *opts.SourceBranch == "renoglaab/revert-1" && *opts.TargetBranch == "main" && assert.ObjectsAreEqual(gitlab.LabelOptions{"renoglaab-revert"}, *opts.Labels)
+				}))
+			} else {
+				mockClient.AssertNotCalled(t, "CreateMergeRequest", mock.Anything, mock.Anything)
+			}
+
+			if tt.expectBranch {
+				mockClient.AssertNumberOfCalls(t, "CreateBranch", 1)
+			} else {
+				mockClient.AssertNotCalled(t, "CreateBranch", mock.Anything, mock.Anything)
+			}
+
+			if tt.expectCommit {
+				mockClient.AssertNumberOfCalls(t, "RevertCommit", 1)
+			} else {
+				mockClient.AssertNotCalled(t, "RevertCommit", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			if tt.expectBlock {
+				mockClient.AssertCalled(t, "UpdateMergeRequest", repo, int64(1), &gitlab.UpdateMergeRequestOptions{AddLabels: &gitlab.LabelOptions{"renoglaab-blocked"}})
+			} else {
+				mockClient.AssertNotCalled(t, "UpdateMergeRequest", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			if tt.expectNote {
+				mockClient.AssertNumberOfCalls(t, "CreateMergeRequestNote", 1)
+			} else {
+				mockClient.AssertNotCalled(t, "CreateMergeRequestNote", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			if tt.revertErr != nil {
+				mockClient.AssertCalled(t, "DeleteBranch", repo, "renoglaab/revert-1")
+			} else {
+				mockClient.AssertNotCalled(t, "DeleteBranch", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestProjectBlocked(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	cfg := config.Config{BlockLabel: "renoglaab-blocked"}
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("ListProjectMergeRequests", repo, mock.MatchedBy(func(opts *gitlab.ListProjectMergeRequestsOptions) bool {
+		return *opts.State == stateMerged && assert.ObjectsAreEqual(gitlab.LabelOptions{"renoglaab-blocked"}, *opts.Labels)
+	})).Return([]*gitlab.BasicMergeRequest{{IID: 1}}, nil)
+
+	blocked, err := projectBlocked(cfg, repo, mockClient)
+	require.NoError(t, err)
+	assert.True(t, blocked)
+}
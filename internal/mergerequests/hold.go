@@ -0,0 +1,121 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// Reasons for rejecting a merge request a human asked renoglaab to leave alone.
+var (
+	errExcludedLabel = errors.New("merge request has an excluded label")
+	errOnHold        = errors.New("merge request is on hold")
+)
+
+const holdCommand = "hold"
+
+// holdCommandRegex matches "renoglaab: hold" and "renoglaab: unhold" at the start of a line.
+var holdCommandRegex = regexp.MustCompile(`(?im)^\s*renoglaab:\s*(hold|unhold)\b`)
+
+// checkExcludedLabels returns an error naming the first excluded label set on the merge request.
+func checkExcludedLabels(excludeLabels []string, mr *gitlab.BasicMergeRequest) error {
+	for _, label := range mr.Labels {
+		if slices.Contains(excludeLabels, label) {
+			return fmt.Errorf("%w: %s", errExcludedLabel, label)
+		}
+	}
+
+	return nil
+}
+
+// checkHold scans the merge request notes for hold and unhold commands.
+// The most recent command wins, so a hold stays in place until someone lifts it.
+func checkHold(repo string, mr *gitlab.BasicMergeRequest, client gl.Client) error {
+	notes, _, err := client.ListMergeRequestNotes(repo, mr.IID, &gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: notesPerPage},
+		OrderBy:     gitlab.Ptr("created_at"),
+		Sort:        gitlab.Ptr("desc"),
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"repository": repo, "mr_id": mr.IID,
+		}).Error("Failed to list merge request notes")
+
+		return err
+	}
+
+	for _, note := range notes {
+		if note.System {
+			continue
+		}
+
+		if _, _, ok := parseNoteMarker(note.Body); ok {
+			continue
+		}
+
+		matches := holdCommandRegex.FindAllStringSubmatch(note.Body, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		if strings.ToLower(matches[len(matches)-1][1]) != holdCommand {
+			return nil
+		}
+
+		return fmt.Errorf("%w: requested by @%s", errOnHold, note.Author.Username)
+	}
+
+	return nil
+}
+
+// excludeLabelsFilter rejects merge requests with any of the excluded labels.
+type excludeLabelsFilter struct{}
+
+func (excludeLabelsFilter) Name() string     { return filterExcludeLabels }
+func (excludeLabelsFilter) Cost() FilterCost { return CostLocal }
+
+func (excludeLabelsFilter) Enabled(config config.Config) bool {
+	return len(config.ExcludeLabels) > 0
+}
+
+func (excludeLabelsFilter) Evaluate(fc *FilterContext) Verdict {
+	evidence := Evidence{"labels": fc.MR.Labels}
+	if err := checkExcludedLabels(fc.Config.ExcludeLabels, fc.MR); err != nil {
+		return failed(err, evidence)
+	}
+
+	return passed(evidence)
+}
+
+func (excludeLabelsFilter) classify(r *rejection) {
+	r.reportable, r.outcome = true, outcomeBlockedPolicy
+}
+
+// holdFilter rejects merge requests someone put on hold.
+type holdFilter struct{}
+
+func (holdFilter) Name() string     { return filterHold }
+func (holdFilter) Cost() FilterCost { return CostAPI }
+
+func (holdFilter) Enabled(config config.Config) bool {
+	return config.FilterByHoldCommand
+}
+
+func (holdFilter) Evaluate(fc *FilterContext) Verdict {
+	if err := checkHold(fc.Repo, fc.MR, fc.Client); err != nil {
+		return failed(err, nil)
+	}
+
+	return passed(nil)
+}
+
+func (holdFilter) classify(r *rejection) {
+	r.reportable, r.outcome = errors.Is(r.err, errOnHold), outcomeBlockedPolicy
+}
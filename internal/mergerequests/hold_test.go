@@ -0,0 +1,93 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestCheckExcludedLabels(t *testing.T) {
+	t.Parallel()
+
+	mr := &gitlab.BasicMergeRequest{Labels: gitlab.Labels{"renovate", "do-not-merge"}}
+
+	require.ErrorIs(t, checkExcludedLabels([]string{"needs-review", "do-not-merge"}, mr), errExcludedLabel)
+	require.NoError(t, checkExcludedLabels([]string{"needs-review"}, mr))
+}
+
+func TestCheckHold(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	mr := &gitlab.BasicMergeRequest{IID: 1}
+	human := gitlab.NoteAuthor{Username: "alice"}
+
+	tests := []struct {
+		name     string
+		notes    []*gitlab.Note
+		listErr  error
+		expected error
+	}{
+		{
+			name:  "No commands",
+			notes: []*gitlab.Note{{Body: "LGTM", Author: human}},
+		},
+		{
+			name:     "Hold",
+			notes:    []*gitlab.Note{{Body: "Waiting for the release.\nrenoglaab: hold", Author: human}},
+			expected: errOnHold,
+		},
+		{
+			name: "Hold lifted by newer note",
+			notes: []*gitlab.Note{
+				{Body: "Renoglaab: unhold", Author: human},
+				{Body: "renoglaab: hold", Author: human},
+			},
+		},
+		{
+			name: "Hold placed after unhold",
+			notes: []*gitlab.Note{
+				{Body: "renoglaab: hold", Author: human},
+				{Body: "renoglaab: unhold", Author: human},
+			},
+			expected: errOnHold,
+		},
+		{
+			name: "Own and system notes are ignored",
+			notes: []*gitlab.Note{
+				{Body: withNoteMarker("renoglaab: hold", noteKindExplanation, "abc")},
+				{Body: "renoglaab: hold", System: true},
+			},
+		},
+		{
+			name:     "Notes cannot be listed",
+			listErr:  errors.New("GitLab API error"),
+			expected: errors.New("GitLab API error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return(tt.notes, tt.listErr)
+
+			err := checkHold(repo, mr, mockClient)
+
+			switch {
+			case tt.expected == nil:
+				require.NoError(t, err)
+			case tt.listErr != nil:
+				assert.EqualError(t, err, tt.expected.Error())
+			default:
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
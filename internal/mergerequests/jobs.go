@@ -0,0 +1,132 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// Reasons for rejecting a pipeline based on its jobs.
+var (
+	errJobFailed          = errors.New("job failed")
+	errJobNotRun          = errors.New("job did not run")
+	errRequiredJobMissing = errors.New("required job not found")
+)
+
+const jobsPerPage = 100
+
+// Job statuses as reported by GitLab.
+const (
+	jobStatusSuccess  = "success"
+	jobStatusFailed   = "failed"
+	jobStatusCanceled = "canceled"
+)
+
+// matchesJob reports whether a job is listed by name or by stage.
+func matchesJob(list []string, job *gitlab.Job) bool {
+	return slices.Contains(list, job.Name) || slices.Contains(list, job.Stage)
+}
+
+// checkPipelineJobs evaluates the jobs of a finished pipeline against the job rules
+// instead of relying on the overall pipeline status.
+func checkPipelineJobs(config config.Config, repo string, pipeline *gitlab.Pipeline, client gl.Client) error {
+	fields := logrus.Fields{"repository": repo, "pipeline_id": pipeline.ID}
+
+	if isPipelineRunning(pipeline.Status) {
+		logrus.WithFields(fields).WithField("pipeline_status", pipeline.Status).Debug("Pipeline has not finished")
+
+		return fmt.Errorf("%w: pipeline #%d is %s", errPipelineRunning, pipeline.ID, pipeline.Status)
+	}
+
+	jobs, err := listPipelineJobs(client, repo, pipeline.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, required := range config.RequiredJobs {
+		if !slices.ContainsFunc(jobs, func(job *gitlab.Job) bool { return matchesJob([]string{required}, job) }) {
+			logrus.WithFields(fields).WithField("job", required).Warn("Required job not found")
+
+			return fmt.Errorf("%w: %s", errRequiredJobMissing, required)
+		}
+	}
+
+	for _, job := range jobs {
+		if err := checkJob(config, pipeline.ID, job); err != nil {
+			logrus.WithError(err).WithFields(fields).WithFields(logrus.Fields{
+				"job": job.Name, "stage": job.Stage, "job_status": job.Status,
+			}).Warn("Job blocks pipeline")
+
+			return err
+		}
+	}
+
+	logrus.WithFields(fields).Debug("Pipeline jobs passed")
+
+	return nil
+}
+
+// checkJob applies the job rules to a single job.
+func checkJob(config config.Config, pipelineID int64, job *gitlab.Job) error {
+	required := matchesJob(config.RequiredJobs, job)
+
+	switch job.Status {
+	case jobStatusSuccess:
+		return nil
+	case jobStatusFailed, jobStatusCanceled:
+		if required {
+			return fmt.Errorf("%w: required job %s in pipeline #%d is %s", errJobFailed, job.Name, pipelineID, job.Status)
+		}
+
+		if matchesJob(config.AllowedFailureJobs, job) {
+			return nil
+		}
+
+		// Failed jobs with allow_failure only turn the pipeline into a warning.
+		if job.AllowFailure && !config.FilterByPipelineWithoutWarnings {
+			return nil
+		}
+
+		return fmt.Errorf("%w: job %s in pipeline #%d is %s", errJobFailed, job.Name, pipelineID, job.Status)
+	default:
+		// Skipped, manual and jobs that never started, e.g. behind a manual job.
+		// Optional manual jobs are marked with allow_failure.
+		if !required && (matchesJob(config.AllowedSkippedJobs, job) || job.AllowFailure) {
+			return nil
+		}
+
+		return fmt.Errorf("%w: job %s in pipeline #%d is %s", errJobNotRun, job.Name, pipelineID, job.Status)
+	}
+}
+
+// listPipelineJobs fetches all jobs of a pipeline, excluding retried ones.
+func listPipelineJobs(client gl.Client, repo string, pipelineID int64) ([]*gitlab.Job, error) {
+	var jobs []*gitlab.Job
+
+	options := &gitlab.ListJobsOptions{ListOptions: gitlab.ListOptions{PerPage: jobsPerPage, Page: 1}}
+
+	for {
+		page, resp, err := client.ListPipelineJobs(repo, pipelineID, options)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"repository":  repo,
+				"pipeline_id": pipelineID,
+			}).Error("Failed to list pipeline jobs")
+
+			return nil, err
+		}
+
+		jobs = append(jobs, page...)
+
+		if resp == nil || resp.NextPage == 0 {
+			return jobs, nil
+		}
+
+		options.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,119 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestCheckPipelineJobs(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	cfg := config.Config{
+		FilterByJobs:                    true,
+		FilterByPipelineWithoutWarnings: true,
+		RequiredJobs:                    []string{"test"},
+		AllowedFailureJobs:              []string{"lint"},
+		AllowedSkippedJobs:              []string{"deploy"},
+	}
+
+	tests := []struct {
+		name     string
+		pipeline *gitlab.Pipeline
+		jobs     []*gitlab.Job
+		listErr  error
+		expected error
+	}{
+		{
+			name:     "All jobs passed",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "success"},
+			jobs:     []*gitlab.Job{{Name: "unit", Stage: "test", Status: "success"}},
+		},
+		{
+			name:     "Allowed failure and skipped stage",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "failed"},
+			jobs: []*gitlab.Job{
+				{Name: "test", Stage: "test", Status: "success"},
+				{Name: "lint", Stage: "lint", Status: "failed"},
+				{Name: "prod", Stage: "deploy", Status: "manual"},
+			},
+		},
+		{
+			name:     "Optional manual job",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "success"},
+			jobs: []*gitlab.Job{
+				{Name: "test", Stage: "test", Status: "success"},
+				{Name: "review", Stage: "review", Status: "manual", AllowFailure: true},
+			},
+		},
+		{
+			name:     "Required job failed",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "failed"},
+			jobs:     []*gitlab.Job{{Name: "test", Stage: "test", Status: "failed", AllowFailure: true}},
+			expected: errJobFailed,
+		},
+		{
+			name:     "Unlisted job with allow_failure fails while warnings are not allowed",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "success"},
+			jobs: []*gitlab.Job{
+				{Name: "test", Stage: "test", Status: "success"},
+				{Name: "audit", Stage: "audit", Status: "failed", AllowFailure: true},
+			},
+			expected: errJobFailed,
+		},
+		{
+			name:     "Unlisted job skipped",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "success"},
+			jobs: []*gitlab.Job{
+				{Name: "test", Stage: "test", Status: "success"},
+				{Name: "e2e", Stage: "e2e", Status: "skipped"},
+			},
+			expected: errJobNotRun,
+		},
+		{
+			name:     "Required job missing",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "success"},
+			jobs:     []*gitlab.Job{{Name: "build", Stage: "build", Status: "success"}},
+			expected: errRequiredJobMissing,
+		},
+		{
+			name:     "Pipeline still running",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "running"},
+			expected: errPipelineRunning,
+		},
+		{
+			name:     "Jobs cannot be listed",
+			pipeline: &gitlab.Pipeline{ID: 1, Status: "success"},
+			listErr:  errors.New("GitLab API error"),
+			expected: errors.New("GitLab API error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("ListPipelineJobs", repo, tt.pipeline.ID, mock.Anything).Return(tt.jobs, tt.listErr).Maybe()
+
+			err := checkPipelineJobs(cfg, repo, tt.pipeline, mockClient)
+
+			switch {
+			case tt.expected == nil:
+				require.NoError(t, err)
+			case tt.listErr != nil:
+				assert.EqualError(t, err, tt.expected.Error())
+			default:
+				assert.ErrorIs(t, err, tt.expected)
+				assert.True(t, isPipelineRejection(err))
+			}
+		})
+	}
+}
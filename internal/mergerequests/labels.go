@@ -0,0 +1,70 @@
+package mergerequests
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// outcome is the decision renoglaab made for a merge request.
+type outcome string
+
+const (
+	outcomeApproved        outcome = "approved"
+	outcomeBlockedPipeline outcome = "blocked-pipeline"
+	outcomeBlockedPolicy   outcome = "blocked-policy"
+	outcomeWaitingPipeline outcome = "waiting-pipeline"
+	outcomeWaitingRebase   outcome = "waiting-rebase"
+)
+
+// waiting reports whether the outcome is expected to change on its own, e.g. once the pipeline finished.
+func (o outcome) waiting() bool {
+	return o == outcomeWaitingPipeline || o == outcomeWaitingRebase
+}
+
+// decisionLabel returns the scoped label for an outcome, e.g. "renoglaab::approved".
+func decisionLabel(scope string, o outcome) string {
+	return scope + "::" + string(o)
+}
+
+// setDecisionLabel adds the label for the outcome and removes labels of previous outcomes.
+func setDecisionLabel(config config.Config, repo string, mr *gitlab.BasicMergeRequest, o outcome, client gl.Client) {
+	label := decisionLabel(config.DecisionLabelScope, o)
+	prefix := config.DecisionLabelScope + "::"
+
+	var (
+		present bool
+		stale   gitlab.LabelOptions
+	)
+
+	for _, existing := range mr.Labels {
+		switch {
+		case existing == label:
+			present = true
+		case strings.HasPrefix(existing, prefix):
+			stale = append(stale, existing)
+		}
+	}
+
+	if present && len(stale) == 0 {
+		return
+	}
+
+	options := &gitlab.UpdateMergeRequestOptions{AddLabels: &gitlab.LabelOptions{label}}
+	if len(stale) > 0 {
+		options.RemoveLabels = &stale
+	}
+
+	fields := logrus.Fields{"repository": repo, "mrID": mr.IID, "label": label}
+
+	if _, _, err := client.UpdateMergeRequest(repo, mr.IID, options); err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to set decision label")
+
+		return
+	}
+
+	logrus.WithFields(fields).Debug("Set decision label")
+}
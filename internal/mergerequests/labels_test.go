@@ -0,0 +1,72 @@
+//nolint:lll,funlen
+package mergerequests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestSetDecisionLabel(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	cfg := config.Config{DecisionLabelScope: "renoglaab"}
+
+	tests := []struct {
+		name         string
+		labels       gitlab.Labels
+		outcome      outcome
+		expectUpdate bool
+		expectAdd    gitlab.LabelOptions
+		expectRemove *gitlab.LabelOptions
+	}{
+		{
+			name:         "No decision label yet",
+			labels:       gitlab.Labels{"renovate"},
+			outcome:      outcomeApproved,
+			expectUpdate: true,
+			expectAdd:    gitlab.LabelOptions{"renoglaab::approved"},
+		},
+		{
+			name:    "Label already set",
+			labels:  gitlab.Labels{"renovate", "renoglaab::blocked-pipeline"},
+			outcome: outcomeBlockedPipeline,
+		},
+		{
+			name:         "Decision changed",
+			labels:       gitlab.Labels{"renovate", "renoglaab::blocked-pipeline"},
+			outcome:      outcomeApproved,
+			expectUpdate: true,
+			expectAdd:    gitlab.LabelOptions{"renoglaab::approved"},
+			expectRemove: &gitlab.LabelOptions{"renoglaab::blocked-pipeline"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mr := &gitlab.BasicMergeRequest{IID: 1, Labels: tt.labels}
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("UpdateMergeRequest", repo, mr.IID, mock.Anything).Return(&gitlab.MergeRequest{}, nil).Maybe()
+
+			setDecisionLabel(cfg, repo, mr, tt.outcome, mockClient)
+
+			if !tt.expectUpdate {
+				mockClient.AssertNotCalled(t, "UpdateMergeRequest", mock.Anything, mock.Anything, mock.Anything)
+
+				return
+			}
+
+			opts, ok := mockClient.Calls[0].Arguments.Get(2).(*gitlab.UpdateMergeRequestOptions)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expectAdd, *opts.AddLabels)
+			assert.Equal(t, tt.expectRemove, opts.RemoveLabels)
+		})
+	}
+}
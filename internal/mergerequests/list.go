@@ -1,29 +1,67 @@
 package mergerequests
 
 import (
+	"errors"
+
 	"github.com/sirupsen/logrus"
 	"github.com/xMoelletschi/renoglaab/internal/config"
 	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/metrics"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
 const stateOpen string = "opened"
 
+var errBranchMismatch = errors.New("branch does not match allowed regex")
+
+// Filter names used for metrics and comment templates.
+const (
+	filterState         = "state"
+	filterAuthor        = "author"
+	filterLabels        = "labels"
+	filterBranch        = "branch"
+	filterExcludeLabels = "exclude-labels"
+	filterMergeability  = "mergeability"
+	filterHold          = "hold"
+	filterRebase        = "rebase"
+	filterPipeline      = "pipeline"
+)
+
+// candidate is a merge request that passed the filters, along with the data gathered while checking it.
+type candidate struct {
+	mr            *gitlab.BasicMergeRequest
+	pipeline      *gitlab.Pipeline
+	passedFilters []string
+	trace         *Trace
+}
+
+// rejection describes why a merge request did not pass the filters.
+type rejection struct {
+	mr       *gitlab.BasicMergeRequest
+	pipeline *gitlab.Pipeline
+	filter   string
+	err      error
+	trace    *Trace
+	// reportable is set if the reason is worth reporting on the MR, i.e.
+	// the MR is meant to be handled by renoglaab and the failure is not a transient API error.
+	reportable bool
+	// retryable is set if the pipeline was rejected because of failed jobs.
+	retryable bool
+	// triggerable is set if there is no pipeline for the head commit.
+	triggerable bool
+	outcome     outcome
+}
+
 // listProjectMergeRequests fetches MRs and filters by branch regex and pipeline status.
-func listProjectMergeRequests(config config.Config, repo string, client gl.Client) []int64 {
+func listProjectMergeRequests(config config.Config, repo string, client gl.Client) ([]*candidate, []*rejection) {
 	logrus.WithField("repository", repo).Debug("Listing merge requests")
 
-	options := &gitlab.ListProjectMergeRequestsOptions{
-		State: gitlab.Ptr(stateOpen),
-	}
-
-	if config.FilterByAuthorUsername {
-		options.AuthorUsername = &config.AuthorUsername
-	}
+	options := &gitlab.ListProjectMergeRequestsOptions{}
 
-	if config.FilterByLabels {
-		labels := gitlab.LabelOptions(config.Labels)
-		options.Labels = &labels
+	for _, f := range builtinFilters {
+		if lf, ok := f.(listingFilter); ok && f.Enabled(config) {
+			lf.listOptions(config, options)
+		}
 	}
 
 	mrs, _, err := client.ListProjectMergeRequests(repo, options)
@@ -31,49 +69,124 @@ func listProjectMergeRequests(config config.Config, repo string, client gl.Clien
 		logrus.WithError(err).WithField("repository", repo).Error("Failed to list MRs")
 	}
 
-	var mrIIDs []int64
+	var (
+		qualified []*candidate
+		rejected  []*rejection
+	)
 
 	for _, mr := range mrs {
-		if shouldProcessMR(repo, mr, config, client) {
-			mrIIDs = append(mrIIDs, mr.IID)
+		metrics.MergeRequestsEvaluated.WithLabelValues(repo).Inc()
+
+		c, r := shouldProcessMR(repo, mr, config, client)
+		if r != nil {
+			rejected = append(rejected, r)
+
+			continue
 		}
+
+		qualified = append(qualified, c)
 	}
 
-	return mrIIDs
+	metrics.MergeRequestsWaiting.WithLabelValues(repo).Set(float64(len(rejected)))
+
+	return qualified, rejected
 }
 
-func shouldProcessMR(repo string, mr *gitlab.BasicMergeRequest, config config.Config, client gl.Client) bool {
+// evaluateOptions controls how evaluateFilters checks a merge request.
+type evaluateOptions struct {
+	// listed is set if the merge request was found by listing, which already applied the listing filters.
+	listed bool
+	// all keeps checking the filters after the first rejection, to get a complete trace.
+	all bool
+}
+
+func shouldProcessMR(
+	repo string, mr *gitlab.BasicMergeRequest, config config.Config, client gl.Client,
+) (*candidate, *rejection) {
+	return evaluateFilters(repo, mr, config, client, evaluateOptions{listed: true})
+}
+
+// evaluateFilters runs the filters on a merge request and records the verdict of each one in a trace.
+// It returns a candidate if every filter passed, or the first rejection otherwise.
+func evaluateFilters(
+	repo string, mr *gitlab.BasicMergeRequest, config config.Config, client gl.Client, opts evaluateOptions,
+) (*candidate, *rejection) {
+	return runFilters(&FilterContext{Config: config, Repo: repo, MR: mr, Client: client, Listed: opts.listed}, opts)
+}
+
+// runFilters runs the filters on the merge request of a context, see evaluateFilters.
+func runFilters(fc *FilterContext, opts evaluateOptions) (*candidate, *rejection) {
+	config, repo, mr := fc.Config, fc.Repo, fc.MR
+
 	logrus.WithFields(logrus.Fields{
 		"repository": repo, "mr_id": mr.IID, "branch": mr.SourceBranch, "title": mr.Title,
 	}).Debug("Checking")
 
-	if config.FilterByBranch {
-		if !config.AllowedBranchRegexCompiled.MatchString(mr.SourceBranch) {
-			logrus.WithFields(logrus.Fields{
-				"repository": repo, "mr_id": mr.IID, "branch": mr.SourceBranch, "title": mr.Title,
-			}).Debug("Branch does not match allowed regex")
+	trace := &Trace{Repository: repo, IID: mr.IID, Title: mr.Title, SHA: mr.SHA}
+
+	var first *rejection
+
+	for _, f := range orderedFilters(config.FilterOrder) {
+		var verdict Verdict
+
+		switch {
+		case !f.Enabled(config):
+			verdict = Verdict{Status: VerdictSkipped, Reason: "disabled"}
+		case first != nil && !opts.all:
+			verdict = Verdict{Status: VerdictSkipped, Reason: "not checked after " + first.filter + " failed"}
+		case fc.Listed && f.Cost() == CostListing:
+			verdict = Verdict{Status: VerdictPassed, Reason: "filtered when listing"}
+		default:
+			verdict = f.Evaluate(fc)
+			metrics.RecordFilter(f.Name(), verdict.Status != VerdictFailed)
+		}
+
+		verdict.Filter = f.Name()
+		trace.add(verdict)
 
-			return false
+		if verdict.Status == VerdictFailed && first == nil {
+			first = newRejection(f, fc, verdict)
 		}
+	}
 
-		logrus.WithFields(logrus.Fields{
-			"repository": repo, "mr_id": mr.IID, "branch": mr.SourceBranch, "title": mr.Title,
-		}).Debug("Branch matches allowed regex")
+	trace.Approvable = first == nil
+	trace.OPAInput = fc.OPAInput
+
+	if config.Policy != "" {
+		trace.PolicyInput = fc.PolicyInput
 	}
 
-	if config.FilterBySucceededPipeline {
-		if !pipelineSucceeded(config, repo, mr.SourceBranch, client) {
-			logrus.WithFields(logrus.Fields{
-				"repository": repo, "mr_id": mr.IID, "branch": mr.SourceBranch, "title": mr.Title,
-			}).Debug("Pipeline failed for MR")
+	if first != nil {
+		trace.RejectedBy, trace.Reason = first.filter, first.err.Error()
+		first.trace = trace
 
-			return false
-		}
+		logrus.WithError(first.err).WithFields(logrus.Fields{
+			"repository": repo, "mr_id": mr.IID, "filter": first.filter, "verdicts": trace.Verdicts,
+		}).Debug("MR rejected")
+
+		return nil, first
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"repository": repo, "mr_id": mr.IID, "verdicts": trace.Verdicts,
+	}).Debug("MR passed every filter")
+
+	return &candidate{mr: mr, pipeline: fc.Pipeline, passedFilters: trace.passed(), trace: trace}, nil
+}
+
+// newRejection describes the failed verdict of a filter. Filters decide themselves whether
+// their rejections are reported or lead to further actions.
+func newRejection(f Filter, fc *FilterContext, verdict Verdict) *rejection {
+	err := verdict.Err
+	if err == nil {
+		err = errors.New(verdict.Reason) //nolint:err113
+	}
+
+	r := &rejection{mr: fc.MR, pipeline: fc.Pipeline, filter: f.Name(), err: err}
 
-		logrus.WithFields(logrus.Fields{
-			"repository": repo, "mr_id": mr.IID, "branch": mr.SourceBranch, "title": mr.Title,
-		}).Debug("Pipeline succeeded for MR")
+	if cf, ok := f.(classifyingFilter); ok {
+		cf.classify(r)
 	}
 
-	return true
+	return r
 }
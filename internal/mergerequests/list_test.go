@@ -2,16 +2,29 @@
 package mergerequests
 
 import (
+	"bytes"
 	"errors"
 	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/state"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
+// newMemoryStore returns an empty state kept in memory.
+func newMemoryStore(t *testing.T) *state.Store {
+	t.Helper()
+
+	store, err := state.Open(nil)
+	require.NoError(t, err)
+
+	return store
+}
+
 // MockGitLabClient is a mock implementation of GitLabClient.
 type MockGitLabClient struct {
 	mock.Mock
@@ -50,6 +63,165 @@ func (m *MockGitLabClient) GetPipeline(repo string, pipelineID int64) (*gitlab.P
 	return pipeline, nil, args.Error(1)
 }
 
+func (m *MockGitLabClient) ListPipelineJobs(repo string, pipelineID int64, opts *gitlab.ListJobsOptions) ([]*gitlab.Job, *gitlab.Response, error) {
+	args := m.Called(repo, pipelineID, opts)
+	jobs, _ := args.Get(0).([]*gitlab.Job)
+
+	return jobs, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) CreatePipeline(repo string, opts *gitlab.CreatePipelineOptions) (*gitlab.Pipeline, *gitlab.Response, error) {
+	args := m.Called(repo, opts)
+	pipeline, _ := args.Get(0).(*gitlab.Pipeline)
+
+	return pipeline, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) RetryPipeline(repo string, pipelineID int64) (*gitlab.Pipeline, *gitlab.Response, error) {
+	args := m.Called(repo, pipelineID)
+	pipeline, _ := args.Get(0).(*gitlab.Pipeline)
+
+	return pipeline, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) RetryJob(repo string, jobID int64) (*gitlab.Job, *gitlab.Response, error) {
+	args := m.Called(repo, jobID)
+	job, _ := args.Get(0).(*gitlab.Job)
+
+	return job, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) GetJobTrace(repo string, jobID int64) (*bytes.Reader, *gitlab.Response, error) {
+	args := m.Called(repo, jobID)
+
+	return bytes.NewReader([]byte(args.String(0))), nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) GetMergeRequest(repo string, mrIID int64, opts *gitlab.GetMergeRequestsOptions) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	args := m.Called(repo, mrIID, opts)
+	mr, _ := args.Get(0).(*gitlab.MergeRequest)
+
+	return mr, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) RebaseMergeRequest(repo string, mrIID int64, opts *gitlab.RebaseMergeRequestOptions) (*gitlab.Response, error) {
+	args := m.Called(repo, mrIID, opts)
+
+	return nil, args.Error(0)
+}
+
+func (m *MockGitLabClient) UpdateMergeRequest(repo string, mrIID int64, opts *gitlab.UpdateMergeRequestOptions) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	args := m.Called(repo, mrIID, opts)
+	mr, _ := args.Get(0).(*gitlab.MergeRequest)
+
+	return mr, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) AcceptMergeRequest(repo string, mrIID int64, opts *gitlab.AcceptMergeRequestOptions) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	args := m.Called(repo, mrIID, opts)
+	mr, _ := args.Get(0).(*gitlab.MergeRequest)
+
+	return mr, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) CreateMergeRequest(repo string, opts *gitlab.CreateMergeRequestOptions) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	args := m.Called(repo, opts)
+	mr, _ := args.Get(0).(*gitlab.MergeRequest)
+
+	return mr, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) GetBranch(repo, branch string) (*gitlab.Branch, *gitlab.Response, error) {
+	args := m.Called(repo, branch)
+	b, _ := args.Get(0).(*gitlab.Branch)
+
+	return b, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) CreateBranch(repo string, opts *gitlab.CreateBranchOptions) (*gitlab.Branch, *gitlab.Response, error) {
+	args := m.Called(repo, opts)
+	branch, _ := args.Get(0).(*gitlab.Branch)
+
+	return branch, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) DeleteBranch(repo, branch string) (*gitlab.Response, error) {
+	args := m.Called(repo, branch)
+
+	return nil, args.Error(0)
+}
+
+func (m *MockGitLabClient) RevertCommit(repo, sha string, opts *gitlab.RevertCommitOptions) (*gitlab.Commit, *gitlab.Response, error) {
+	args := m.Called(repo, sha, opts)
+	commit, _ := args.Get(0).(*gitlab.Commit)
+
+	return commit, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) CreateMergeRequestNote(repo string, mrIID int64, opts *gitlab.CreateMergeRequestNoteOptions) (*gitlab.Note, *gitlab.Response, error) {
+	args := m.Called(repo, mrIID, opts)
+	note, _ := args.Get(0).(*gitlab.Note)
+
+	return note, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) ListMergeRequestNotes(repo string, mrIID int64, opts *gitlab.ListMergeRequestNotesOptions) ([]*gitlab.Note, *gitlab.Response, error) {
+	args := m.Called(repo, mrIID, opts)
+	notes, _ := args.Get(0).([]*gitlab.Note)
+
+	return notes, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) UpdateMergeRequestNote(repo string, mrIID, noteID int64, opts *gitlab.UpdateMergeRequestNoteOptions) (*gitlab.Note, *gitlab.Response, error) {
+	args := m.Called(repo, mrIID, noteID, opts)
+	note, _ := args.Get(0).(*gitlab.Note)
+
+	return note, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) DeleteMergeRequestNote(repo string, mrIID, noteID int64) (*gitlab.Response, error) {
+	args := m.Called(repo, mrIID, noteID)
+
+	return nil, args.Error(0)
+}
+
+func (m *MockGitLabClient) GetMergeRequestApprovals(repo string, mrIID int64) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+	args := m.Called(repo, mrIID)
+	approvals, _ := args.Get(0).(*gitlab.MergeRequestApprovals)
+
+	return approvals, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) ApproveMergeRequest(repo string, mrIID int64, opts *gitlab.ApproveMergeRequestOptions) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+	args := m.Called(repo, mrIID, opts)
+	approvals, _ := args.Get(0).(*gitlab.MergeRequestApprovals)
+
+	return approvals, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) CurrentUser() (*gitlab.User, *gitlab.Response, error) {
+	args := m.Called()
+	user, _ := args.Get(0).(*gitlab.User)
+
+	return user, nil, args.Error(1)
+}
+
+func (m *MockGitLabClient) GetProject(repo string) (*gitlab.Project, *gitlab.Response, error) {
+	args := m.Called(repo)
+	project, _ := args.Get(0).(*gitlab.Project)
+
+	return project, nil, args.Error(1)
+}
+
+func mergeRequestIIDs(candidates []*candidate) []int64 {
+	var iids []int64
+	for _, c := range candidates {
+		iids = append(iids, c.mr.IID)
+	}
+
+	return iids
+}
+
 func TestListProjectMergeRequests(t *testing.T) {
 	t.Parallel()
 
@@ -149,8 +321,8 @@ func TestListProjectMergeRequests(t *testing.T) {
 				}
 			}
 
-			result := listProjectMergeRequests(config, repo, mockClient)
-			assert.Equal(t, tt.expectIDs, result)
+			result, _ := listProjectMergeRequests(config, repo, mockClient)
+			assert.Equal(t, tt.expectIDs, mergeRequestIIDs(result))
 		})
 	}
 }
@@ -0,0 +1,69 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// Reasons for rejecting a merge request that cannot be merged.
+var (
+	errDraft                 = errors.New("merge request is a draft")
+	errConflicts             = errors.New("merge request has conflicts")
+	errUnresolvedDiscussions = errors.New("merge request has unresolved blocking discussions")
+	errNotMergeable          = errors.New("merge request is not mergeable")
+)
+
+// mergeabilityChecked reports whether any mergeability condition is enabled.
+func mergeabilityChecked(config config.Config) bool {
+	return config.FilterDraft || config.FilterConflicts ||
+		config.FilterUnresolvedDiscussions || config.FilterNotMergeable
+}
+
+// checkMergeability returns the first enabled condition that prevents the merge request from being merged.
+// It only uses the fields returned when listing merge requests, so no API calls are made.
+func checkMergeability(config config.Config, mr *gitlab.BasicMergeRequest) error {
+	switch {
+	case config.FilterDraft && mr.Draft:
+		return errDraft
+	case config.FilterConflicts && mr.HasConflicts:
+		return errConflicts
+	case config.FilterUnresolvedDiscussions && !mr.BlockingDiscussionsResolved:
+		return errUnresolvedDiscussions
+	case config.FilterNotMergeable && !slices.Contains(config.MergeableStatuses, mr.DetailedMergeStatus) &&
+		!(config.RebaseBehindTarget && mr.DetailedMergeStatus == mergeStatusNeedRebase):
+		return fmt.Errorf("%w: detailed merge status is %s", errNotMergeable, mr.DetailedMergeStatus)
+	}
+
+	return nil
+}
+
+// mergeabilityFilter rejects merge requests that can't be merged.
+type mergeabilityFilter struct{}
+
+func (mergeabilityFilter) Name() string     { return filterMergeability }
+func (mergeabilityFilter) Cost() FilterCost { return CostLocal }
+
+func (mergeabilityFilter) Enabled(config config.Config) bool {
+	return mergeabilityChecked(config)
+}
+
+func (mergeabilityFilter) Evaluate(fc *FilterContext) Verdict {
+	evidence := Evidence{
+		"draft": fc.MR.Draft, "has_conflicts": fc.MR.HasConflicts,
+		"blocking_discussions_resolved": fc.MR.BlockingDiscussionsResolved,
+		"detailed_merge_status":         fc.MR.DetailedMergeStatus,
+	}
+	if err := checkMergeability(fc.Config, fc.MR); err != nil {
+		return failed(err, evidence)
+	}
+
+	return passed(evidence)
+}
+
+func (mergeabilityFilter) classify(r *rejection) {
+	r.reportable, r.outcome = true, outcomeBlockedPolicy
+}
@@ -0,0 +1,66 @@
+//nolint:lll
+package mergerequests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestCheckMergeability(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{
+		FilterDraft:                 true,
+		FilterConflicts:             true,
+		FilterUnresolvedDiscussions: true,
+		FilterNotMergeable:          true,
+		MergeableStatuses:           []string{"mergeable", "not_approved"},
+	}
+
+	mergeable := func() *gitlab.BasicMergeRequest {
+		return &gitlab.BasicMergeRequest{IID: 1, BlockingDiscussionsResolved: true, DetailedMergeStatus: "not_approved"}
+	}
+
+	draft := mergeable()
+	draft.Draft = true
+
+	conflicting := mergeable()
+	conflicting.HasConflicts = true
+
+	discussions := mergeable()
+	discussions.BlockingDiscussionsResolved = false
+
+	blocked := mergeable()
+	blocked.DetailedMergeStatus = "requested_changes"
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		mr       *gitlab.BasicMergeRequest
+		expected error
+	}{
+		{name: "Mergeable", cfg: cfg, mr: mergeable()},
+		{name: "Draft", cfg: cfg, mr: draft, expected: errDraft},
+		{name: "Conflicts", cfg: cfg, mr: conflicting, expected: errConflicts},
+		{name: "Unresolved discussions", cfg: cfg, mr: discussions, expected: errUnresolvedDiscussions},
+		{name: "Blocking merge status", cfg: cfg, mr: blocked, expected: errNotMergeable},
+		{name: "Draft allowed", cfg: config.Config{}, mr: draft},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkMergeability(tt.cfg, tt.mr)
+			if tt.expected == nil {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
@@ -1,25 +1,190 @@
 package mergerequests
 
 import (
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"github.com/xMoelletschi/renoglaab/internal/config"
 	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/metrics"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
-func ReconcileProjectMergeRequests(config config.Config, repo string, client gl.ClientWrapper) {
-	mrs := listProjectMergeRequests(config, repo, &client)
-	for _, mr := range mrs {
-		comment := config.Approve
-		if config.AddComment {
-			comment = config.Comment
+func ReconcileProjectMergeRequests(config config.Config, repo string, client gl.Client, store *state.Store) {
+	start := time.Now()
+	defer func() {
+		metrics.ReconcileDuration.WithLabelValues(repo).Observe(time.Since(start).Seconds())
+	}()
+
+	if config.CircuitBreaker && circuitOpen(repo, store, client) {
+		return
+	}
+
+	failures := reconcileProject(config, repo, client, store)
+
+	if config.CircuitBreaker {
+		recordActionFailures(config, repo, store, failures)
+	}
+}
+
+// reconcileProject approves, merges and repairs the merge requests of a project.
+// It returns the number of actions that failed.
+func reconcileProject(config config.Config, repo string, client gl.Client, store *state.Store) int {
+	failures := 0
+
+	// Notes are only trusted if renoglaab posted them, so nothing is done without knowing its user.
+	user, _, err := client.CurrentUser()
+	if err != nil {
+		logrus.WithError(err).WithField("repository", repo).Error("Failed to fetch current user, skipping repository")
+
+		return 1
+	}
+
+	if config.PostMergeCheck {
+		checkMergedHealth(config, repo, user, client, store)
+	}
+
+	candidates, rejections := listProjectMergeRequests(config, repo, client)
+
+	for _, r := range rejections {
+		if config.RetryFailedPipelines && r.retryable && retryPipeline(config, repo, r, user, client, store) != nil {
+			failures++
 		}
 
-		err := createMergeRequestNote(repo, mr, comment, client.Client)
+		if config.TriggerMissingPipelines && r.triggerable && triggerPipeline(config, repo, r, user, client, store) != nil {
+			failures++
+		}
 
+		if r.reportable {
+			reportRejection(config, repo, r, user, client, store)
+		}
+	}
+
+	if config.PostMergeCheck {
+		blocked, err := projectBlocked(config, repo, client)
 		if err != nil {
-			logrus.WithError(err).Error("Failed to create Merge request note")
+			logrus.WithError(err).WithField("repository", repo).Error("Failed to check whether the project is blocked")
+
+			return failures
+		}
+
+		if blocked {
+			logrus.WithFields(logrus.Fields{
+				"repository": repo, "label": config.BlockLabel,
+			}).Warn("Project is blocked after a merge broke the target branch, not approving or merging")
+
+			return failures
+		}
+	}
+
+	for _, c := range candidates {
+		if _, err := approveCandidate(config, repo, c, user, client, store, false); err != nil {
+			failures++
 		}
+	}
+
+	// Merging changes the target branch, so nothing is rebased while the queue still has candidates.
+	var actionErr error
+
+	switch {
+	case config.MergeQueue && len(candidates) > 0:
+		actionErr = mergeNext(config, repo, candidates, rejections, client)
+	case config.RebaseBehindTarget:
+		actionErr = rebaseNext(config, repo, rejections, client)
+	}
 
-		logrus.WithFields(logrus.Fields{"repository": repo, "mrID": mr}).Info("Approved MR")
+	if actionErr != nil {
+		failures++
 	}
+
+	return failures
+}
+
+// reportRejection records why a merge request was not approved using the configured actions.
+// Only final outcomes are explained, a note about a running pipeline would be deleted again once it passes.
+func reportRejection(
+	config config.Config, repo string, r *rejection, user *gitlab.User, client gl.Client, store *state.Store,
+) {
+	if config.ExplainRejections && !r.outcome.waiting() {
+		explainRejection(repo, r, user, client, store)
+	}
+
+	if config.LabelDecisions {
+		setDecisionLabel(config, repo, r.mr, r.outcome, client)
+	}
+}
+
+// approveCandidate approves a merge request that passed the filters and records the decision.
+// With force, it is approved again even if renoglaab already handled it at its current head.
+func approveCandidate(
+	config config.Config, repo string, c *candidate, user *gitlab.User, client gl.Client, store *state.Store, force bool,
+) (bool, error) {
+	if config.ExplainRejections {
+		clearExplanation(repo, c.mr, user, client, store)
+	}
+
+	approved, err := approveMergeRequest(config, repo, c, user, client, store, force)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"repository": repo, "mrID": c.mr.IID}).Error("Failed to create Merge request note")
+
+		return false, err
+	}
+
+	if config.LabelDecisions {
+		setDecisionLabel(config, repo, c.mr, outcomeApproved, client)
+	}
+
+	if approved {
+		metrics.MergeRequestsApproved.WithLabelValues(repo).Inc()
+		logrus.WithFields(logrus.Fields{"repository": repo, "mrID": c.mr.IID}).Info("Approved MR")
+	}
+
+	return approved, nil
+}
+
+// approveMergeRequest posts the approval note unless renoglaab already handled the MR at its current head.
+// It returns false if nothing had to be done.
+func approveMergeRequest(
+	config config.Config, repo string, c *candidate, user *gitlab.User, client gl.Client, store *state.Store, force bool,
+) (bool, error) {
+	mr := c.mr
+	fields := logrus.Fields{"repository": repo, "mrID": mr.IID, "sha": mr.SHA}
+
+	approved, err := approvedBy(repo, mr.IID, user, client)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to fetch merge request approvals")
+	}
+
+	if approved {
+		logrus.WithFields(fields).Debug("MR already approved, skipping")
+
+		return false, nil
+	}
+
+	note, err := postedNote(repo, mr.IID, noteKindApproval, user, client, store)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to list merge request notes")
+	}
+
+	if note != nil && note.SHA == mr.SHA && !force {
+		logrus.WithFields(fields).Debug("MR already handled at this commit, skipping")
+
+		return false, nil
+	}
+
+	comment, err := renderComment(config, c)
+	if err != nil {
+		return false, err
+	}
+
+	// Quick actions only run when a note is created, so an outdated note can
+	// only be reused if the comment doesn't rely on one.
+	if hasQuickAction(comment) {
+		note = nil
+	}
+
+	body := withNoteMarker(comment, noteKindApproval, mr.SHA)
+
+	return true, writeNote(repo, mr.IID, noteKindApproval, mr.SHA, body, note, client, store)
 }
@@ -0,0 +1,242 @@
+package mergerequests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// noteKind identifies the purpose of a note posted by renoglaab.
+type noteKind string
+
+const (
+	noteKindApproval    noteKind = "approval"
+	noteKindExplanation noteKind = "explanation"
+	noteKindRetry       noteKind = "retry"
+	noteKindTrigger     noteKind = "trigger"
+	noteKindHealth      noteKind = "health"
+)
+
+const notesPerPage = 100
+
+// noteMarkerRegex matches the hidden marker renoglaab appends to its notes.
+var noteMarkerRegex = regexp.MustCompile(`<!-- renoglaab:(\S+) sha=(\S*)((?: \S+=\S*)*) -->`)
+
+// noteMarker returns the hidden HTML comment identifying a note of the given kind for a head SHA.
+// Additional state can be stored as "key=value" attributes.
+func noteMarker(kind noteKind, sha string, attributes ...string) string {
+	marker := fmt.Sprintf("<!-- renoglaab:%s sha=%s", kind, sha)
+	for _, attribute := range attributes {
+		marker += " " + attribute
+	}
+
+	return marker + " -->"
+}
+
+// withNoteMarker appends the hidden marker to a note body.
+func withNoteMarker(body string, kind noteKind, sha string, attributes ...string) string {
+	return body + "\n\n" + noteMarker(kind, sha, attributes...)
+}
+
+// noteMarkerAttribute returns the value of an attribute stored in the marker of a note body.
+func noteMarkerAttribute(body, key string) (string, bool) {
+	matches := noteMarkerRegex.FindStringSubmatch(body)
+	if matches == nil {
+		return "", false
+	}
+
+	for attribute := range strings.FieldsSeq(matches[3]) {
+		if name, value, ok := strings.Cut(attribute, "="); ok && name == key {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// parseNoteMarker extracts the kind and head SHA from a note body.
+func parseNoteMarker(body string) (noteKind, string, bool) {
+	matches := noteMarkerRegex.FindStringSubmatch(body)
+	if matches == nil {
+		return "", "", false
+	}
+
+	return noteKind(matches[1]), matches[2], true
+}
+
+// hasQuickAction reports whether a note body contains a GitLab quick action.
+// Quick actions are only executed when a note is created, never on update.
+func hasQuickAction(body string) bool {
+	for line := range strings.SplitSeq(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findMarkedNote returns the most recent note of the given kind posted by user, if any.
+// Without the user no note is found, as anyone could post a marker to suppress an action.
+func findMarkedNote(
+	repo string, mrIID int64, kind noteKind, user *gitlab.User, client gl.Client,
+) (*gitlab.Note, string, error) {
+	if user == nil {
+		return nil, "", nil
+	}
+
+	notes, _, err := client.ListMergeRequestNotes(repo, mrIID, &gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: notesPerPage},
+		OrderBy:     gitlab.Ptr("created_at"),
+		Sort:        gitlab.Ptr("desc"),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, note := range notes {
+		if note.System || note.Author.ID != user.ID {
+			continue
+		}
+
+		if noteKind, sha, ok := parseNoteMarker(note.Body); ok && noteKind == kind {
+			return note, sha, nil
+		}
+	}
+
+	return nil, "", nil
+}
+
+// postedNote returns the note of the given kind renoglaab posted on a merge request, as recorded in the state.
+// Without a record, e.g. because the state is only kept in memory, the notes of the merge request are searched
+// for the marker instead, and the note found is recorded along with the state its marker holds.
+func postedNote(
+	repo string, mrIID int64, kind noteKind, user *gitlab.User, client gl.Client, store *state.Store,
+) (*state.Note, error) {
+	mr := store.MergeRequest(repo, mrIID)
+	if note, ok := mr.Notes[string(kind)]; ok {
+		return &note, nil
+	}
+
+	note, sha, err := findMarkedNote(repo, mrIID, kind, user, client)
+	if err != nil || note == nil {
+		return nil, err
+	}
+
+	posted := state.Note{ID: note.ID, SHA: sha, Digest: noteDigest(note.Body)}
+
+	importNoteMarker(&mr, kind, sha, note.Body)
+	mr.Notes = withNote(mr.Notes, kind, posted)
+	store.SetMergeRequest(repo, mrIID, mr)
+
+	return &posted, nil
+}
+
+// importNoteMarker takes over the state stored in the marker of a note posted without a persistent state.
+func importNoteMarker(mr *state.MergeRequest, kind noteKind, sha, body string) {
+	switch kind {
+	case noteKindRetry:
+		if value, ok := noteMarkerAttribute(body, retriesAttribute); ok && mr.RetriedSHA == "" {
+			mr.Retries, _ = strconv.Atoi(value)
+			mr.RetriedSHA = sha
+		}
+	case noteKindTrigger:
+		value, _ := noteMarkerAttribute(body, triggeredAtAttribute)
+		if triggeredAt, err := strconv.ParseInt(value, 10, 64); err == nil && mr.TriggeredSHA == "" {
+			mr.TriggeredAt = time.Unix(triggeredAt, 0)
+			mr.TriggeredSHA = sha
+		}
+	case noteKindApproval, noteKindExplanation, noteKindHealth:
+	}
+}
+
+// writeNote updates the note renoglaab posted before, or creates one if there is none or it was deleted,
+// and records it in the state.
+func writeNote(
+	repo string, mrIID int64, kind noteKind, sha, body string, posted *state.Note, client gl.Client, store *state.Store,
+) error {
+	var (
+		id  int64
+		err error
+	)
+
+	if posted != nil {
+		id = posted.ID
+		err = updateMergeRequestNote(repo, mrIID, id, body, client)
+	}
+
+	if posted == nil || errors.Is(err, gitlab.ErrNotFound) {
+		id, err = createMergeRequestNote(repo, mrIID, body, client)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	mr := store.MergeRequest(repo, mrIID)
+	mr.Notes = withNote(mr.Notes, kind, state.Note{ID: id, SHA: sha, Digest: noteDigest(body)})
+	store.SetMergeRequest(repo, mrIID, mr)
+
+	return nil
+}
+
+// forgetNote removes a deleted note from the state.
+func forgetNote(repo string, mrIID int64, kind noteKind, store *state.Store) {
+	mr := store.MergeRequest(repo, mrIID)
+	delete(mr.Notes, string(kind))
+	store.SetMergeRequest(repo, mrIID, mr)
+}
+
+func withNote(notes map[string]state.Note, kind noteKind, note state.Note) map[string]state.Note {
+	if notes == nil {
+		notes = map[string]state.Note{}
+	}
+
+	notes[string(kind)] = note
+
+	return notes
+}
+
+// noteDigest identifies a note body without keeping it in the state.
+func noteDigest(body string) string {
+	sum := sha256.Sum256([]byte(body))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// approvedBy reports whether user is among the approvers of a merge request.
+func approvedBy(repo string, mrIID int64, user *gitlab.User, client gl.Client) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+
+	approvals, _, err := client.GetMergeRequestApprovals(repo, mrIID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil && approver.User.ID == user.ID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func updateMergeRequestNote(repo string, mr, noteID int64, body string, client gl.Client) error {
+	_, _, err := client.UpdateMergeRequestNote(repo, mr, noteID, &gitlab.UpdateMergeRequestNoteOptions{
+		Body: gitlab.Ptr(body),
+	})
+
+	return err
+}
@@ -0,0 +1,155 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestParseNoteMarker(t *testing.T) {
+	t.Parallel()
+
+	kind, sha, ok := parseNoteMarker(withNoteMarker("Approving merge request! :ship:", noteKindApproval, "abc123"))
+	assert.True(t, ok)
+	assert.Equal(t, noteKindApproval, kind)
+	assert.Equal(t, "abc123", sha)
+
+	_, _, ok = parseNoteMarker("Approving merge request! :ship:")
+	assert.False(t, ok)
+}
+
+func TestHasQuickAction(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, hasQuickAction("/approve"))
+	assert.True(t, hasQuickAction("Looks good\n  /merge"))
+	assert.False(t, hasQuickAction("Approving merge request! :ship:"))
+}
+
+func TestFindMarkedNote(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	notes := []*gitlab.Note{
+		{ID: 8, Author: gitlab.NoteAuthor{ID: 7}, Body: withNoteMarker("/approve", noteKindApproval, "forged")},
+		{ID: 7, Author: gitlab.NoteAuthor{ID: 42}, Body: withNoteMarker("/approve", noteKindApproval, "abc")},
+	}
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("ListMergeRequestNotes", repo, int64(1), mock.Anything).Return(notes, nil)
+
+	note, sha, err := findMarkedNote(repo, 1, noteKindApproval, &gitlab.User{ID: 42}, mockClient)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), note.ID)
+	assert.Equal(t, "abc", sha)
+
+	// Without the current user, no marker can be trusted.
+	note, _, err = findMarkedNote(repo, 1, noteKindApproval, nil, mockClient)
+	require.NoError(t, err)
+	assert.Nil(t, note)
+}
+
+func TestApproveMergeRequest(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "new"}
+
+	ownNote := func(sha, comment string) *gitlab.Note {
+		return &gitlab.Note{ID: 7, Body: withNoteMarker(comment, noteKindApproval, sha), Author: gitlab.NoteAuthor{ID: 42}}
+	}
+
+	tests := []struct {
+		name       string
+		addComment bool
+		approvedBy []*gitlab.MergeRequestApproverUser
+		notes      []*gitlab.Note
+		expectCall string
+		expected   bool
+	}{
+		{
+			name:       "Already approved by token user",
+			approvedBy: []*gitlab.MergeRequestApproverUser{{User: &gitlab.BasicUser{ID: 42}}},
+			expected:   false,
+		},
+		{
+			name:     "Note for current head exists",
+			notes:    []*gitlab.Note{ownNote("new", "/approve")},
+			expected: false,
+		},
+		{
+			name:       "Marker from another user is ignored",
+			notes:      []*gitlab.Note{{ID: 8, Body: withNoteMarker("/approve", noteKindApproval, "new"), Author: gitlab.NoteAuthor{ID: 1}}},
+			expectCall: "CreateMergeRequestNote",
+			expected:   true,
+		},
+		{
+			name:       "Outdated note with quick action creates a new note",
+			notes:      []*gitlab.Note{ownNote("old", "/approve")},
+			expectCall: "CreateMergeRequestNote",
+			expected:   true,
+		},
+		{
+			name:       "Outdated note without quick action is updated",
+			addComment: true,
+			notes:      []*gitlab.Note{ownNote("old", "Approving merge request! :ship:")},
+			expectCall: "UpdateMergeRequestNote",
+			expected:   true,
+		},
+		{
+			name:       "No previous action",
+			expectCall: "CreateMergeRequestNote",
+			expected:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := config.Config{AddComment: tt.addComment, Comment: "Approving merge request! :ship:", Approve: "/approve"}
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("GetMergeRequestApprovals", repo, mr.IID).Return(&gitlab.MergeRequestApprovals{ApprovedBy: tt.approvedBy}, nil)
+			mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return(tt.notes, nil).Maybe()
+			mockClient.On("CreateMergeRequestNote", repo, mr.IID, mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+			mockClient.On("UpdateMergeRequestNote", repo, mr.IID, int64(7), mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+
+			approved, err := approveMergeRequest(cfg, repo, &candidate{mr: mr}, user, mockClient, newMemoryStore(t), false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, approved)
+
+			for _, method := range []string{"CreateMergeRequestNote", "UpdateMergeRequestNote"} {
+				expectedCalls := 0
+				if method == tt.expectCall {
+					expectedCalls = 1
+				}
+
+				mockClient.AssertNumberOfCalls(t, method, expectedCalls)
+			}
+		})
+	}
+}
+
+func TestApproveMergeRequestNoteError(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "new"}
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("GetMergeRequestApprovals", repo, mr.IID).Return(nil, errors.New("forbidden"))
+	mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return(nil, errors.New("forbidden"))
+	mockClient.On("CreateMergeRequestNote", repo, mr.IID, mock.Anything).Return(nil, errors.New("forbidden"))
+
+	approved, err := approveMergeRequest(config.Config{Approve: "/approve"}, repo, &candidate{mr: mr}, &gitlab.User{ID: 42}, mockClient, newMemoryStore(t), false)
+	require.Error(t, err)
+	assert.True(t, approved)
+}
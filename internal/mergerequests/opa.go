@@ -0,0 +1,230 @@
+package mergerequests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const (
+	filterOPA  = "opa"
+	opaTimeout = 10 * time.Second
+)
+
+var (
+	errOPADenied    = errors.New("OPA denied the merge request")
+	errOPAUndefined = errors.New("OPA returned no decision")
+	errOPAStatus    = errors.New("unexpected status from OPA")
+)
+
+// opaClient sends the queries to OPA.
+var opaClient = &http.Client{Timeout: opaTimeout}
+
+// OPAInput is the input document sent to OPA for a merge request. Besides the fields of PolicyInput,
+// it holds the recent pipelines of the source branch and the settings of the project.
+type OPAInput struct {
+	PolicyInput
+
+	// Pipelines are the pipelines of the source branch, latest first.
+	Pipelines []OPAPipeline `json:"pipelines"`
+	Project   OPAProject    `json:"project"`
+}
+
+// OPAPipeline describes a pipeline of the source branch.
+type OPAPipeline struct {
+	ID     int64  `json:"id"`
+	SHA    string `json:"sha"`
+	Status string `json:"status"`
+	Source string `json:"source"`
+}
+
+// OPAProject holds the settings of the project relevant to merging.
+type OPAProject struct {
+	ID                                        int64    `json:"id"`
+	Path                                      string   `json:"path"`
+	DefaultBranch                             string   `json:"default_branch"`
+	Visibility                                string   `json:"visibility"`
+	Archived                                  bool     `json:"archived"`
+	Topics                                    []string `json:"topics"`
+	MergeMethod                               string   `json:"merge_method"`
+	SquashOption                              string   `json:"squash_option"`
+	OnlyAllowMergeIfPipelineSucceeds          bool     `json:"only_allow_merge_if_pipeline_succeeds"`
+	OnlyAllowMergeIfAllDiscussionsAreResolved bool     `json:"only_allow_merge_if_all_discussions_are_resolved"`
+	AllowMergeOnSkippedPipeline               bool     `json:"allow_merge_on_skipped_pipeline"`
+}
+
+// OPADecision is the document OPA returns for OPA_PACKAGE. A merge request is approved if allow is true
+// and nothing denies it. The deny messages and reasons explain the decision.
+type OPADecision struct {
+	Allow   bool     `json:"allow"`
+	Deny    []string `json:"deny"`
+	Reasons []string `json:"reasons"`
+}
+
+// approved reports whether the decision approves the merge request.
+func (d OPADecision) approved() bool {
+	return d.Allow && len(d.Deny) == 0
+}
+
+// explanation joins the deny messages and reasons.
+func (d OPADecision) explanation() string {
+	return strings.Join(append(append([]string{}, d.Deny...), d.Reasons...), "; ")
+}
+
+// newOPAInput describes a merge request for OPA, fetching the pipelines of its source branch and the project settings.
+func newOPAInput(input *PolicyInput, fc *FilterContext) (*OPAInput, error) {
+	pipelines, err := listPipelines(fc.Client, fc.Repo, fc.MR.SourceBranch, "")
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := getProject(fc.Client, fc.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	opaInput := &OPAInput{
+		PolicyInput: *input,
+		Pipelines:   make([]OPAPipeline, 0, len(pipelines)),
+		Project: OPAProject{
+			ID:                               project.ID,
+			Path:                             project.PathWithNamespace,
+			DefaultBranch:                    project.DefaultBranch,
+			Visibility:                       string(project.Visibility),
+			Archived:                         project.Archived,
+			Topics:                           project.Topics,
+			MergeMethod:                      string(project.MergeMethod),
+			SquashOption:                     string(project.SquashOption),
+			OnlyAllowMergeIfPipelineSucceeds: project.OnlyAllowMergeIfPipelineSucceeds,
+			OnlyAllowMergeIfAllDiscussionsAreResolved: project.OnlyAllowMergeIfAllDiscussionsAreResolved,
+			AllowMergeOnSkippedPipeline:               project.AllowMergeOnSkippedPipeline,
+		},
+	}
+
+	for _, pipeline := range pipelines {
+		opaInput.Pipelines = append(opaInput.Pipelines, OPAPipeline{
+			ID: pipeline.ID, SHA: pipeline.SHA, Status: pipeline.Status, Source: string(pipeline.Source),
+		})
+	}
+
+	return opaInput, nil
+}
+
+func getProject(client gl.Client, repo string) (*gitlab.Project, error) {
+	project, _, err := client.GetProject(repo)
+	if err != nil {
+		logrus.WithError(err).WithField("repository", repo).Error("Failed to get project")
+
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// opaDecisionURL returns the URL of the OPA Data API document of a package, e.g.
+// http://localhost:8181/v1/data/renoglaab/approval for the package renoglaab.approval.
+func opaDecisionURL(baseURL, pkg string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/v1/data/" + strings.ReplaceAll(pkg, ".", "/")
+}
+
+// queryOPA asks OPA for the decision on an input document.
+func queryOPA(url string, input *OPAInput) (OPADecision, error) {
+	var decision OPADecision
+
+	body, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return decision, err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return decision, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := opaClient.Do(req)
+	if err != nil {
+		return decision, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return decision, fmt.Errorf("%w: POST %s: %s", errOPAStatus, url, resp.Status)
+	}
+
+	var result struct {
+		Result *OPADecision `json:"result"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return decision, err
+	}
+
+	// OPA leaves out the result if the package is undefined.
+	if result.Result == nil {
+		return decision, fmt.Errorf("%w at %s", errOPAUndefined, url)
+	}
+
+	return *result.Result, nil
+}
+
+// opaFilter only lets merge requests pass the OPA policy at OPA_PACKAGE allows.
+type opaFilter struct{}
+
+func (opaFilter) Name() string     { return filterOPA }
+func (opaFilter) Cost() FilterCost { return CostAPI }
+
+func (opaFilter) Enabled(config config.Config) bool {
+	return config.OPAURL != ""
+}
+
+func (opaFilter) Evaluate(fc *FilterContext) Verdict {
+	url := opaDecisionURL(fc.Config.OPAURL, fc.Config.OPAPackage)
+	evidence := Evidence{"url": url}
+
+	input, err := policyInput(fc)
+	if err != nil {
+		return failed(err, evidence)
+	}
+
+	if fc.OPAInput, err = newOPAInput(input, fc); err != nil {
+		return failed(err, evidence)
+	}
+
+	decision, err := queryOPA(url, fc.OPAInput)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"repository": fc.Repo, "mr_id": fc.MR.IID}).Error("Failed to query OPA")
+
+		return failed(err, evidence)
+	}
+
+	if len(decision.Reasons) > 0 {
+		evidence["reasons"] = decision.Reasons
+	}
+
+	if !decision.approved() {
+		reason := decision.explanation()
+		if reason == "" {
+			reason = "allow is not true"
+		}
+
+		return failed(fmt.Errorf("%w: %s", errOPADenied, reason), evidence)
+	}
+
+	return passed(evidence)
+}
+
+func (opaFilter) classify(r *rejection) {
+	r.reportable, r.outcome = errors.Is(r.err, errOPADenied), outcomeBlockedPolicy
+}
@@ -0,0 +1,127 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestOPAFilter(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+
+	tests := []struct {
+		name             string
+		status           int
+		response         string
+		expectError      error
+		expectReason     string
+		expectReportable bool
+	}{
+		{
+			name:     "Allowed",
+			status:   http.StatusOK,
+			response: `{"result": {"allow": true, "reasons": ["patch of a devDependency"]}}`,
+		},
+		{
+			name:             "Denied",
+			status:           http.StatusOK,
+			response:         `{"result": {"allow": true, "deny": ["major update of react"], "reasons": ["needs a review"]}}`,
+			expectError:      errOPADenied,
+			expectReason:     "OPA denied the merge request: major update of react; needs a review",
+			expectReportable: true,
+		},
+		{
+			name:             "Not allowed",
+			status:           http.StatusOK,
+			response:         `{"result": {}}`,
+			expectError:      errOPADenied,
+			expectReason:     "OPA denied the merge request: allow is not true",
+			expectReportable: true,
+		},
+		{
+			name:        "Undefined package",
+			status:      http.StatusOK,
+			response:    `{}`,
+			expectError: errOPAUndefined,
+		},
+		{
+			name:        "Server error",
+			status:      http.StatusInternalServerError,
+			response:    `{"code": "internal_error"}`,
+			expectError: errOPAStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var request struct {
+				Input OPAInput `json:"input"`
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/v1/data/renovate/approval", r.URL.Path)
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "abc", SourceBranch: "renovate/react-19.x", Title: "Update dependency react to v19"}
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("ListProjectPipelines", repo, mock.MatchedBy(func(opts *gitlab.ListProjectPipelinesOptions) bool { return opts.SHA != nil })).
+				Return([]*gitlab.PipelineInfo{{ID: 100}}, nil)
+			mockClient.On("ListProjectPipelines", repo, mock.MatchedBy(func(opts *gitlab.ListProjectPipelinesOptions) bool { return opts.SHA == nil })).
+				Return([]*gitlab.PipelineInfo{{ID: 100, SHA: "abc", Status: "success", Source: "push"}, {ID: 90, SHA: "old", Status: "failed", Source: "push"}}, nil)
+			mockClient.On("GetPipeline", repo, int64(100)).Return(&gitlab.Pipeline{ID: 100, Status: "success"}, nil)
+			mockClient.On("ListPipelineJobs", repo, int64(100), mock.Anything).Return([]*gitlab.Job{{Name: "test", Stage: "test", Status: "success"}}, nil)
+			mockClient.On("GetProject", repo).Return(&gitlab.Project{ID: 7, PathWithNamespace: repo, DefaultBranch: "main", MergeMethod: gitlab.FastForwardMerge, OnlyAllowMergeIfPipelineSucceeds: true}, nil)
+
+			cfg := config.Config{OPAURL: server.URL + "/", OPAPackage: "renovate.approval"}
+
+			_, r := shouldProcessMR(repo, mr, cfg, mockClient)
+
+			assert.Equal(t, OPAInput{
+				PolicyInput: PolicyInput{
+					Repository: repo,
+					MR:         PolicyMergeRequest{IID: 1, Title: "Update dependency react to v19", SourceBranch: "renovate/react-19.x", SHA: "abc"},
+					Pipeline:   &PolicyPipeline{ID: 100, Status: "success", Jobs: []PolicyJob{{Name: "test", Stage: "test", Status: "success"}}},
+					Renovate:   PolicyRenovate{Updates: []PolicyUpdate{{Package: "react", To: "v19"}}, Packages: []string{"react"}},
+				},
+				Pipelines: []OPAPipeline{{ID: 100, SHA: "abc", Status: "success", Source: "push"}, {ID: 90, SHA: "old", Status: "failed", Source: "push"}},
+				Project:   OPAProject{ID: 7, Path: repo, DefaultBranch: "main", MergeMethod: "ff", OnlyAllowMergeIfPipelineSucceeds: true},
+			}, request.Input)
+
+			if tt.expectError == nil {
+				require.Nil(t, r)
+
+				return
+			}
+
+			require.NotNil(t, r)
+			require.ErrorIs(t, r.err, tt.expectError)
+			assert.Equal(t, filterOPA, r.filter)
+			assert.Equal(t, tt.expectReportable, r.reportable)
+			assert.NotNil(t, r.trace.OPAInput)
+			assert.Nil(t, r.trace.PolicyInput, "the policy input is only recorded with POLICY")
+
+			if tt.expectReason != "" {
+				assert.Equal(t, tt.expectReason, r.err.Error())
+			}
+		})
+	}
+}
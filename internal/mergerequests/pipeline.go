@@ -1,38 +1,107 @@
 package mergerequests
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/sirupsen/logrus"
 	"github.com/xMoelletschi/renoglaab/internal/config"
 	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
+// Reasons for rejecting a pipeline, as opposed to failing to fetch it.
+var (
+	errNoPipeline           = errors.New("no pipeline found")
+	errPipelineNotSucceeded = errors.New("pipeline did not succeed")
+	errPipelineRunning      = errors.New("pipeline has not finished")
+	errPipelineWarnings     = errors.New("pipeline succeeded with warnings")
+)
+
+// isPipelineRejection reports whether err is a pipeline rejection rather than an API error.
+func isPipelineRejection(err error) bool {
+	return errors.Is(err, errNoPipeline) ||
+		errors.Is(err, errPipelineNotSucceeded) ||
+		errors.Is(err, errPipelineRunning) ||
+		errors.Is(err, errPipelineWarnings) ||
+		errors.Is(err, errJobFailed) ||
+		errors.Is(err, errJobNotRun) ||
+		errors.Is(err, errRequiredJobMissing)
+}
+
 // pipelineSucceeded checks if the latest pipeline for a branch succeeded without warnings.
-func pipelineSucceeded(config config.Config, repo, branch string, client gl.Client) bool {
+// If sha is set, only pipelines for that commit are considered.
+// It returns the checked pipeline if one could be fetched, and an error explaining why it did not qualify.
+func pipelineSucceeded(config config.Config, repo, branch, sha string, client gl.Client) (*gitlab.Pipeline, error) {
 	logrus.WithFields(logrus.Fields{
 		"repository": repo,
 		"branch":     branch,
 	}).Debug("Checking pipeline status for branch")
 
-	pipelines, err := listPipelines(client, repo, branch)
-	if err != nil || len(pipelines) == 0 {
-		return false
+	pipelines, err := listPipelines(client, repo, branch, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pipelines) == 0 {
+		return nil, errNoPipeline
 	}
 
 	latestPipeline := pipelines[0]
 	pipeline, err := getPipeline(client, repo, latestPipeline.ID)
 
 	if err != nil {
-		return false
+		return nil, err
+	}
+
+	if config.FilterByJobs {
+		return pipeline, checkPipelineJobs(config, repo, pipeline, client)
+	}
+
+	return pipeline, checkPipelineStatus(config, pipeline)
+}
+
+// pipelineFilter only lets merge requests pass whose latest pipeline succeeded, or whose jobs
+// passed the job rules with FILTER_BY_JOBS.
+type pipelineFilter struct{}
+
+func (pipelineFilter) Name() string     { return filterPipeline }
+func (pipelineFilter) Cost() FilterCost { return CostAPI }
+
+func (pipelineFilter) Enabled(config config.Config) bool {
+	return config.FilterBySucceededPipeline
+}
+
+func (pipelineFilter) Evaluate(fc *FilterContext) Verdict {
+	if fc.Provider != nil {
+		return evaluateCheckStatus(fc)
+	}
+
+	pipeline, err := pipelineSucceeded(fc.Config, fc.Repo, fc.MR.SourceBranch, fc.MR.SHA, fc.Client)
+	fc.Pipeline = pipeline
+
+	if err != nil {
+		return failed(err, pipelineEvidence(pipeline))
 	}
 
-	return checkPipelineStatus(config, repo, latestPipeline.ID, pipeline)
+	return passed(pipelineEvidence(pipeline))
 }
 
-func listPipelines(client gl.Client, repo, branch string) ([]*gitlab.PipelineInfo, error) {
-	pipelines, _, err := client.ListProjectPipelines(repo, &gitlab.ListProjectPipelinesOptions{
+func (pipelineFilter) classify(r *rejection) {
+	r.reportable, r.outcome = isPipelineRejection(r.err), pipelineOutcome(r.err)
+	r.retryable, r.triggerable = isRetryableFailure(r.err), errors.Is(r.err, errNoPipeline)
+}
+
+func listPipelines(client gl.Client, repo, branch, sha string) ([]*gitlab.PipelineInfo, error) {
+	options := &gitlab.ListProjectPipelinesOptions{
 		Ref: &branch, // Filter by branch
-	})
+	}
+
+	if sha != "" {
+		options.SHA = &sha
+	}
+
+	pipelines, _, err := client.ListProjectPipelines(repo, options)
 	if err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
 			"repository": repo,
@@ -46,6 +115,7 @@ func listPipelines(client gl.Client, repo, branch string) ([]*gitlab.PipelineInf
 		logrus.WithFields(logrus.Fields{
 			"repository": repo,
 			"branch":     branch,
+			"sha":        sha,
 		}).Warn("No pipelines found for branch")
 	}
 
@@ -66,32 +136,58 @@ func getPipeline(client gl.Client, repo string, pipelineID int64) (*gitlab.Pipel
 	return pipeline, nil
 }
 
-func checkPipelineStatus(config config.Config, repo string, pipelineID int64, pipeline *gitlab.Pipeline) bool {
+// pipelineOutcome maps a pipeline rejection to the decision outcome.
+func pipelineOutcome(err error) outcome {
+	if errors.Is(err, errPipelineRunning) || errors.Is(err, errNoPipeline) {
+		return outcomeWaitingPipeline
+	}
+
+	return outcomeBlockedPipeline
+}
+
+// isPipelineRunning reports whether a pipeline status means it has not finished yet.
+func isPipelineRunning(status string) bool {
+	switch status {
+	case "created", "waiting_for_resource", "preparing", "pending", "running", "scheduled":
+		return true
+	}
+
+	return false
+}
+
+// checkPipelineStatus checks the overall status of a finished pipeline.
+func checkPipelineStatus(config config.Config, pipeline *gitlab.Pipeline) error {
+	if isPipelineRunning(pipeline.Status) {
+		return fmt.Errorf("%w: pipeline #%d is %s", errPipelineRunning, pipeline.ID, pipeline.Status)
+	}
+
 	if pipeline.Status != "success" {
-		logrus.WithFields(logrus.Fields{
-			"repository":      repo,
-			"pipeline_id":     pipelineID,
-			"pipeline_status": pipeline.Status,
-			"pipeline_icon":   pipeline.DetailedStatus.Icon,
-		}).Warn("Pipeline did not succeed")
+		return fmt.Errorf("%w: pipeline #%d is %s", errPipelineNotSucceeded, pipeline.ID, pipeline.Status)
+	}
 
-		return false
+	if config.FilterByPipelineWithoutWarnings && pipelineIcon(pipeline) != "status_success" {
+		return fmt.Errorf("%w: pipeline #%d", errPipelineWarnings, pipeline.ID)
 	}
 
-	if config.FilterByPipelineWithoutWarnings && pipeline.DetailedStatus.Icon != "status_success" {
-		logrus.WithFields(logrus.Fields{
-			"repository":    repo,
-			"pipeline_id":   pipelineID,
-			"pipeline_icon": pipeline.DetailedStatus.Icon,
-		}).Warn("Pipeline succeeded but has warnings")
+	return nil
+}
 
-		return false
+// pipelineIcon returns the icon GitLab shows for the detailed status of a pipeline, e.g. status_warning.
+func pipelineIcon(pipeline *gitlab.Pipeline) string {
+	if pipeline.DetailedStatus == nil {
+		return ""
 	}
 
-	logrus.WithFields(logrus.Fields{
-		"repository":  repo,
-		"pipeline_id": pipelineID,
-	}).Debug("Pipeline succeeded")
+	return pipeline.DetailedStatus.Icon
+}
 
-	return true
+// pipelineEvidence describes the checked pipeline, if one was found.
+func pipelineEvidence(pipeline *gitlab.Pipeline) Evidence {
+	if pipeline == nil {
+		return nil
+	}
+
+	return Evidence{
+		"pipeline_id": pipeline.ID, "status": pipeline.Status, "icon": pipelineIcon(pipeline), "web_url": pipeline.WebURL,
+	}
 }
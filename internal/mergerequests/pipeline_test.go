@@ -25,11 +25,13 @@ func TestPipelineSucceeded(t *testing.T) {
 		listErr   error
 		getErr    error
 		expected  bool
+		rejection bool
 	}{
 		{
 			name:      "No pipelines found",
 			pipelines: []*gitlab.PipelineInfo{},
 			expected:  false,
+			rejection: true,
 		},
 		{
 			name:     "Failed to list pipelines",
@@ -47,12 +49,21 @@ func TestPipelineSucceeded(t *testing.T) {
 			pipelines: []*gitlab.PipelineInfo{{ID: 100}},
 			pipeline:  &gitlab.Pipeline{Status: "failed", DetailedStatus: &gitlab.DetailedStatus{Icon: "failed"}},
 			expected:  false,
+			rejection: true,
+		},
+		{
+			name:      "Pipeline still running",
+			pipelines: []*gitlab.PipelineInfo{{ID: 100}},
+			pipeline:  &gitlab.Pipeline{Status: "running", DetailedStatus: &gitlab.DetailedStatus{Icon: "status_running"}},
+			expected:  false,
+			rejection: true,
 		},
 		{
 			name:      "Pipeline succeeded but has warnings",
 			pipelines: []*gitlab.PipelineInfo{{ID: 100}},
 			pipeline:  &gitlab.Pipeline{Status: "success", DetailedStatus: &gitlab.DetailedStatus{Icon: "status_warning"}},
 			expected:  false,
+			rejection: true,
 		},
 		{
 			name:      "Pipeline succeeded without warnings",
@@ -74,8 +85,14 @@ func TestPipelineSucceeded(t *testing.T) {
 				mockClient.On("GetPipeline", repo, tt.pipelines[0].ID).Return(tt.pipeline, tt.getErr).Once()
 			}
 
-			result := pipelineSucceeded(config, repo, branch, mockClient)
-			assert.Equal(t, tt.expected, result)
+			pipeline, err := pipelineSucceeded(config, repo, branch, "", mockClient)
+			assert.Equal(t, tt.expected, err == nil)
+
+			if tt.getErr == nil {
+				assert.Equal(t, tt.pipeline, pipeline)
+			}
+
+			assert.Equal(t, tt.rejection, isPipelineRejection(err))
 		})
 	}
 }
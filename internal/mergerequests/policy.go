@@ -0,0 +1,260 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/renovate"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const filterPolicy = "policy"
+
+var (
+	errInvalidPolicy = errors.New("invalid policy")
+	errPolicyDenied  = errors.New("policy denied the merge request")
+	errPolicyFailed  = errors.New("policy evaluation failed")
+)
+
+// PolicyInput is the object a POLICY expression is evaluated against. Recorded as JSON,
+// it is the input of a policy test fixture.
+type PolicyInput struct {
+	Repository string             `json:"repository" expr:"repository"`
+	MR         PolicyMergeRequest `json:"mr"         expr:"mr"`
+	// Pipeline is the latest pipeline of the head commit, nil if there is none.
+	Pipeline *PolicyPipeline `json:"pipeline" expr:"pipeline"`
+	Renovate PolicyRenovate  `json:"renovate" expr:"renovate"`
+}
+
+// PolicyMergeRequest describes the merge request.
+type PolicyMergeRequest struct {
+	IID          int64     `json:"iid"           expr:"iid"`
+	Title        string    `json:"title"         expr:"title"`
+	SourceBranch string    `json:"source_branch" expr:"source_branch"`
+	TargetBranch string    `json:"target_branch" expr:"target_branch"`
+	Author       string    `json:"author"        expr:"author"`
+	Labels       []string  `json:"labels"        expr:"labels"`
+	Draft        bool      `json:"draft"         expr:"draft"`
+	SHA          string    `json:"sha"           expr:"sha"`
+	WebURL       string    `json:"web_url"       expr:"web_url"`
+	CreatedAt    time.Time `json:"created_at"    expr:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"    expr:"updated_at"`
+	// AgeHours is the time since the merge request was created, as of the evaluation.
+	AgeHours float64 `json:"age_hours" expr:"age_hours"`
+}
+
+// PolicyPipeline describes a pipeline and its jobs.
+type PolicyPipeline struct {
+	ID     int64       `json:"id"      expr:"id"`
+	Status string      `json:"status"  expr:"status"`
+	Icon   string      `json:"icon"    expr:"icon"`
+	WebURL string      `json:"web_url" expr:"web_url"`
+	Jobs   []PolicyJob `json:"jobs"    expr:"jobs"`
+}
+
+// PolicyJob describes a job of a pipeline.
+type PolicyJob struct {
+	Name         string `json:"name"          expr:"name"`
+	Stage        string `json:"stage"         expr:"stage"`
+	Status       string `json:"status"        expr:"status"`
+	AllowFailure bool   `json:"allow_failure" expr:"allow_failure"`
+}
+
+// PolicyRenovate holds the Renovate metadata parsed from the title and description.
+type PolicyRenovate struct {
+	Updates     []PolicyUpdate `json:"updates"      expr:"updates"`
+	Packages    []string       `json:"packages"     expr:"packages"`
+	UpdateTypes []string       `json:"update_types" expr:"update_types"`
+}
+
+// PolicyUpdate describes a single dependency update.
+type PolicyUpdate struct {
+	Package    string `json:"package"     expr:"package"`
+	DepType    string `json:"dep_type"    expr:"dep_type"`
+	UpdateType string `json:"update_type" expr:"update_type"`
+	From       string `json:"from"        expr:"from"`
+	To         string `json:"to"          expr:"to"`
+}
+
+// PolicyFixture is a recorded policy input along with the decision the policy is expected to make.
+type PolicyFixture struct {
+	Name   string      `json:"name"`
+	Expect bool        `json:"expect"`
+	Input  PolicyInput `json:"input"`
+}
+
+// policies caches the compiled programs by expression.
+var policies sync.Map
+
+// compilePolicy compiles and type-checks a policy expression against PolicyInput.
+func compilePolicy(expression string) (*vm.Program, error) {
+	if program, ok := policies.Load(expression); ok {
+		return program.(*vm.Program), nil //nolint:forcetypeassert
+	}
+
+	program, err := expr.Compile(expression, expr.Env(PolicyInput{}), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidPolicy, err)
+	}
+
+	policies.Store(expression, program)
+
+	return program, nil
+}
+
+// ValidatePolicy checks that POLICY is a valid expression returning a boolean.
+func ValidatePolicy(expression string) error {
+	if expression == "" {
+		return nil
+	}
+
+	if _, err := compilePolicy(expression); err != nil {
+		return fmt.Errorf("POLICY: %w", err)
+	}
+
+	return nil
+}
+
+// EvaluatePolicy reports whether the policy expression approves the input.
+func EvaluatePolicy(expression string, input PolicyInput) (bool, error) {
+	program, err := compilePolicy(expression)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := expr.Run(program, input)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", errPolicyFailed, err)
+	}
+
+	approved, _ := result.(bool)
+
+	return approved, nil
+}
+
+// newPolicyInput describes a merge request, its pipeline and jobs for a policy evaluated at now.
+func newPolicyInput(
+	repo string, mr *gitlab.BasicMergeRequest, pipeline *gitlab.Pipeline, jobs []*gitlab.Job, now time.Time,
+) *PolicyInput {
+	info := renovate.Parse(mr.Title, mr.Description)
+
+	input := &PolicyInput{
+		Repository: repo,
+		MR: PolicyMergeRequest{
+			IID:          mr.IID,
+			Title:        mr.Title,
+			SourceBranch: mr.SourceBranch,
+			TargetBranch: mr.TargetBranch,
+			Labels:       mr.Labels,
+			Draft:        mr.Draft,
+			SHA:          mr.SHA,
+			WebURL:       mr.WebURL,
+		},
+		Renovate: PolicyRenovate{Packages: info.Packages(), UpdateTypes: info.UpdateTypes()},
+	}
+
+	if mr.Author != nil {
+		input.MR.Author = mr.Author.Username
+	}
+
+	if mr.CreatedAt != nil {
+		input.MR.CreatedAt = *mr.CreatedAt
+		input.MR.AgeHours = now.Sub(*mr.CreatedAt).Hours()
+	}
+
+	if mr.UpdatedAt != nil {
+		input.MR.UpdatedAt = *mr.UpdatedAt
+	}
+
+	for _, update := range info.Updates {
+		input.Renovate.Updates = append(input.Renovate.Updates, PolicyUpdate{
+			Package: update.Package, DepType: update.DepType, UpdateType: update.UpdateType, From: update.From, To: update.To,
+		})
+	}
+
+	if pipeline != nil {
+		input.Pipeline = &PolicyPipeline{ID: pipeline.ID, Status: pipeline.Status, Icon: pipelineIcon(pipeline), WebURL: pipeline.WebURL}
+
+		for _, job := range jobs {
+			input.Pipeline.Jobs = append(input.Pipeline.Jobs, PolicyJob{
+				Name: job.Name, Stage: job.Stage, Status: job.Status, AllowFailure: job.AllowFailure,
+			})
+		}
+	}
+
+	return input
+}
+
+// policyInput describes the merge request of the context for the policy filters. It fetches the latest
+// pipeline of the head commit, unless the pipeline filter already did, and its jobs.
+func policyInput(fc *FilterContext) (*PolicyInput, error) {
+	if fc.PolicyInput != nil {
+		return fc.PolicyInput, nil
+	}
+
+	pipeline := fc.Pipeline
+	if pipeline == nil {
+		pipelines, err := listPipelines(fc.Client, fc.Repo, fc.MR.SourceBranch, fc.MR.SHA)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pipelines) > 0 {
+			if pipeline, err = getPipeline(fc.Client, fc.Repo, pipelines[0].ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var jobs []*gitlab.Job
+
+	if pipeline != nil {
+		var err error
+		if jobs, err = listPipelineJobs(fc.Client, fc.Repo, pipeline.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	fc.PolicyInput = newPolicyInput(fc.Repo, fc.MR, pipeline, jobs, time.Now())
+
+	return fc.PolicyInput, nil
+}
+
+// policyFilter only lets merge requests pass the POLICY expression approves.
+type policyFilter struct{}
+
+func (policyFilter) Name() string     { return filterPolicy }
+func (policyFilter) Cost() FilterCost { return CostAPI }
+
+func (policyFilter) Enabled(config config.Config) bool {
+	return config.Policy != ""
+}
+
+func (policyFilter) Evaluate(fc *FilterContext) Verdict {
+	evidence := Evidence{"policy": fc.Config.Policy}
+
+	input, err := policyInput(fc)
+	if err != nil {
+		return failed(err, evidence)
+	}
+
+	approved, err := EvaluatePolicy(fc.Config.Policy, *input)
+	if err != nil {
+		return failed(err, evidence)
+	}
+
+	if !approved {
+		return failed(errPolicyDenied, evidence)
+	}
+
+	return passed(evidence)
+}
+
+func (policyFilter) classify(r *rejection) {
+	r.reportable, r.outcome = errors.Is(r.err, errPolicyDenied), outcomeBlockedPolicy
+}
@@ -0,0 +1,135 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const testPolicy = `(mr.age_hours >= 24 && all(renovate.updates, .update_type == "patch" && .dep_type == "devDependencies")) ||
+	(all(renovate.updates, .update_type == "digest") && pipeline != nil && all(pipeline.jobs, .status == "success"))`
+
+func TestValidatePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		policy      string
+		expectError string
+	}{
+		{name: "Not set"},
+		{name: "Valid", policy: testPolicy},
+		{name: "Syntax error", policy: `mr.draft ==`, expectError: "POLICY: invalid policy: unexpected token EOF"},
+		{name: "Unknown field", policy: `mr.titel == "foo"`, expectError: "has no field titel"},
+		{name: "Not a boolean", policy: `mr.iid`, expectError: "expected bool, but got int64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidatePolicy(tt.policy)
+			if tt.expectError == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.ErrorIs(t, err, errInvalidPolicy)
+			assert.ErrorContains(t, err, tt.expectError)
+		})
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	t.Parallel()
+
+	devPatch := PolicyRenovate{Updates: []PolicyUpdate{{Package: "eslint", DepType: "devDependencies", UpdateType: "patch"}}}
+	digest := PolicyRenovate{Updates: []PolicyUpdate{{Package: "node", UpdateType: "digest"}}}
+
+	tests := []struct {
+		name           string
+		input          PolicyInput
+		expectApproved bool
+	}{
+		{
+			name:           "Patch of a devDependency after a day",
+			input:          PolicyInput{MR: PolicyMergeRequest{AgeHours: 30}, Renovate: devPatch},
+			expectApproved: true,
+		},
+		{
+			name:  "Patch of a devDependency too early",
+			input: PolicyInput{MR: PolicyMergeRequest{AgeHours: 2}, Renovate: devPatch},
+		},
+		{
+			name:           "Digest with passed jobs",
+			input:          PolicyInput{Renovate: digest, Pipeline: &PolicyPipeline{Jobs: []PolicyJob{{Name: "test", Status: "success"}}}},
+			expectApproved: true,
+		},
+		{
+			name:  "Digest with failed job",
+			input: PolicyInput{Renovate: digest, Pipeline: &PolicyPipeline{Jobs: []PolicyJob{{Name: "test", Status: "failed"}}}},
+		},
+		{
+			name:  "Digest without pipeline",
+			input: PolicyInput{Renovate: digest},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			approved, err := EvaluatePolicy(testPolicy, tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectApproved, approved)
+		})
+	}
+
+	_, err := EvaluatePolicy(`pipeline.status == "success"`, PolicyInput{})
+	require.ErrorIs(t, err, errPolicyFailed)
+}
+
+func TestPolicyFilter(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	created := time.Now().Add(-48 * time.Hour)
+	mr := &gitlab.BasicMergeRequest{
+		IID: 1, SHA: "abc", SourceBranch: "renovate/eslint-8.x", CreatedAt: &created,
+		Title:  "chore(deps): update dependency eslint to v8.2.1",
+		Author: &gitlab.BasicUser{Username: "renovate-bot"},
+	}
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("ListProjectPipelines", repo, mock.Anything).Return([]*gitlab.PipelineInfo{{ID: 100}}, nil)
+	mockClient.On("GetPipeline", repo, int64(100)).Return(&gitlab.Pipeline{ID: 100, Status: "success"}, nil)
+	mockClient.On("ListPipelineJobs", repo, int64(100), mock.Anything).Return([]*gitlab.Job{{Name: "lint", Stage: "test", Status: "failed", AllowFailure: true}}, nil)
+
+	cfg := config.Config{Policy: `mr.author == "renovate-bot" && mr.age_hours > 24 && "eslint" in renovate.packages && all(pipeline.jobs, .status == "success" || .allow_failure)`}
+
+	_, r := shouldProcessMR(repo, mr, cfg, mockClient)
+	require.Nil(t, r)
+
+	cfg.Policy = `renovate.update_types == ["major"]`
+
+	_, r = shouldProcessMR(repo, mr, cfg, mockClient)
+	require.NotNil(t, r)
+	require.ErrorIs(t, r.err, errPolicyDenied)
+	assert.Equal(t, filterPolicy, r.filter)
+	assert.True(t, r.reportable)
+	assert.Equal(t, outcomeBlockedPolicy, r.outcome)
+
+	input := r.trace.PolicyInput
+	require.NotNil(t, input)
+	assert.Equal(t, "renovate-bot", input.MR.Author)
+	assert.InDelta(t, 48, input.MR.AgeHours, 0.1)
+	assert.Equal(t, []PolicyJob{{Name: "lint", Stage: "test", Status: "failed", AllowFailure: true}}, input.Pipeline.Jobs)
+	assert.Equal(t, []PolicyUpdate{{Package: "eslint", To: "v8.2.1"}}, input.Renovate.Updates)
+}
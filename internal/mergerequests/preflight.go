@@ -0,0 +1,68 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// Reasons for a project failing the preflight check.
+var (
+	errProjectNotFound       = errors.New("project not found or not visible to the token")
+	errProjectArchived       = errors.New("project is archived")
+	errMergeRequestsDisabled = errors.New("merge requests are disabled")
+	errInsufficientAccess    = errors.New("token has insufficient access")
+)
+
+// PreflightProject checks that renoglaab can work on the merge requests of a project:
+// it exists, isn't archived, has merge requests enabled and the token has at least the Developer role.
+func PreflightProject(repo string, client gl.Client) error {
+	project, _, err := client.GetProject(repo)
+	if errors.Is(err, gitlab.ErrNotFound) {
+		return errProjectNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if project.Archived {
+		return errProjectArchived
+	}
+
+	if project.MergeRequestsAccessLevel == gitlab.DisabledAccessControl {
+		return errMergeRequestsDisabled
+	}
+
+	// Administrators may have access without being a member, in which case no access level is reported.
+	if level, ok := accessLevel(project); ok && level < gitlab.DeveloperPermissions {
+		return fmt.Errorf("%w: access level is %d, approving needs at least %d (Developer)",
+			errInsufficientAccess, level, gitlab.DeveloperPermissions)
+	}
+
+	return nil
+}
+
+// accessLevel returns the highest access level of the token user, directly or through a group, if GitLab reported one.
+func accessLevel(project *gitlab.Project) (gitlab.AccessLevelValue, bool) {
+	if project.Permissions == nil {
+		return 0, false
+	}
+
+	var (
+		level gitlab.AccessLevelValue
+		ok    bool
+	)
+
+	if access := project.Permissions.ProjectAccess; access != nil {
+		level, ok = access.AccessLevel, true
+	}
+
+	if access := project.Permissions.GroupAccess; access != nil && access.AccessLevel > level {
+		level, ok = access.AccessLevel, true
+	}
+
+	return level, ok
+}
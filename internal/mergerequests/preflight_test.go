@@ -0,0 +1,81 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestPreflightProject(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	developer := &gitlab.Permissions{ProjectAccess: &gitlab.ProjectAccess{AccessLevel: gitlab.DeveloperPermissions}}
+
+	tests := []struct {
+		name        string
+		project     *gitlab.Project
+		projectErr  error
+		expectError error
+	}{
+		{
+			name:    "Developer on an active project",
+			project: &gitlab.Project{MergeRequestsAccessLevel: gitlab.EnabledAccessControl, Permissions: developer},
+		},
+		{
+			name: "Maintainer through a group",
+			project: &gitlab.Project{Permissions: &gitlab.Permissions{
+				ProjectAccess: &gitlab.ProjectAccess{AccessLevel: gitlab.ReporterPermissions},
+				GroupAccess:   &gitlab.GroupAccess{AccessLevel: gitlab.MaintainerPermissions},
+			}},
+		},
+		{
+			name:    "No access level reported",
+			project: &gitlab.Project{Permissions: &gitlab.Permissions{}},
+		},
+		{
+			name:        "Not found",
+			projectErr:  gitlab.ErrNotFound,
+			expectError: errProjectNotFound,
+		},
+		{
+			name:        "Archived",
+			project:     &gitlab.Project{Archived: true, Permissions: developer},
+			expectError: errProjectArchived,
+		},
+		{
+			name:        "Merge requests disabled",
+			project:     &gitlab.Project{MergeRequestsAccessLevel: gitlab.DisabledAccessControl, Permissions: developer},
+			expectError: errMergeRequestsDisabled,
+		},
+		{
+			name:        "Reporter",
+			project:     &gitlab.Project{Permissions: &gitlab.Permissions{ProjectAccess: &gitlab.ProjectAccess{AccessLevel: gitlab.ReporterPermissions}}},
+			expectError: errInsufficientAccess,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("GetProject", repo).Return(tt.project, tt.projectErr)
+
+			err := PreflightProject(repo, mockClient)
+			if tt.expectError != nil {
+				require.ErrorIs(t, err, tt.expectError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("GetProject", repo).Return(nil, errors.New("API error"))
+	assert.Error(t, PreflightProject(repo, mockClient))
+}
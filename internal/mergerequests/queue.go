@@ -0,0 +1,176 @@
+package mergerequests
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/metrics"
+	"github.com/xMoelletschi/renoglaab/internal/renovate"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// updateTypeRanks orders the update types by risk, so the least risky updates are merged first.
+// Unknown update types are merged last.
+var updateTypeRanks = map[string]int{
+	renovate.UpdateTypePatch:  0,
+	renovate.UpdateTypeDigest: 1,
+	renovate.UpdateTypePin:    1,
+	renovate.UpdateTypeMinor:  2,
+	renovate.UpdateTypeMajor:  3,
+}
+
+const unknownUpdateTypeRank = 4
+
+// settleTimeout is how long a running pipeline holds back the queue of its project. A pipeline stuck,
+// e.g. in created because a job waits for a runner that never comes, doesn't block it any longer.
+const settleTimeout = time.Hour
+
+// mergeNext merges the first candidate in queue order.
+// Only one merge request is merged per project and run: merging changes the target
+// branch, so the remaining ones are rebased and tested again before they are merged.
+// It returns an error if the merge failed.
+func mergeNext(config config.Config, repo string, candidates []*candidate, rejections []*rejection, client gl.Client) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if r := unsettled(rejections); r != nil {
+		logrus.WithFields(logrus.Fields{
+			"repository": repo, "mrID": r.mr.IID,
+		}).Debug("Waiting for MR to settle before merging another one")
+
+		return nil
+	}
+
+	mrs := make([]*gitlab.BasicMergeRequest, 0, len(candidates))
+	for _, c := range candidates {
+		mrs = append(mrs, c.mr)
+	}
+
+	next := orderQueue(config.MergeQueueOrder, repo, mrs, client)[0]
+	fields := logrus.Fields{"repository": repo, "mrID": next.IID, "sha": next.SHA}
+
+	// Passing the SHA makes GitLab refuse the merge if the branch changed since it was checked.
+	_, _, err := client.AcceptMergeRequest(repo, next.IID, &gitlab.AcceptMergeRequestOptions{
+		SHA: gitlab.Ptr(next.SHA),
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to merge merge request")
+
+		return err
+	}
+
+	metrics.MergeRequestsMerged.WithLabelValues(repo).Inc()
+	logrus.WithFields(fields).Info("Merged MR")
+
+	return nil
+}
+
+// unsettled returns a rejected merge request that is still being rebased or whose pipeline is running.
+// Merge requests without a pipeline, e.g. because CI was skipped, never settle on their own and are ignored,
+// as are pipelines running for longer than settleTimeout.
+func unsettled(rejections []*rejection) *rejection {
+	for _, r := range rejections {
+		if errors.Is(r.err, errRebaseInProgress) {
+			return r
+		}
+
+		if errors.Is(r.err, errPipelineRunning) && !isPipelineStuck(r.pipeline) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// isPipelineStuck reports whether a pipeline was created longer than settleTimeout ago.
+func isPipelineStuck(pipeline *gitlab.Pipeline) bool {
+	return pipeline != nil && pipeline.CreatedAt != nil && time.Since(*pipeline.CreatedAt) > settleTimeout
+}
+
+// orderQueue returns the merge requests in the order they should be merged or rebased.
+// Ties are broken by age, oldest first.
+func orderQueue(order, repo string, mrs []*gitlab.BasicMergeRequest, client gl.Client) []*gitlab.BasicMergeRequest {
+	ranks := make(map[int64]int, len(mrs))
+	for _, mr := range mrs {
+		ranks[mr.IID] = queueRank(order, repo, mr, client)
+	}
+
+	sorted := slices.Clone(mrs)
+	slices.SortStableFunc(sorted, func(a, b *gitlab.BasicMergeRequest) int {
+		return cmp.Or(cmp.Compare(ranks[a.IID], ranks[b.IID]), compareAge(a, b))
+	})
+
+	return sorted
+}
+
+// queueRank ranks a merge request for the given order, lower ranks come first.
+func queueRank(order, repo string, mr *gitlab.BasicMergeRequest, client gl.Client) int {
+	switch order {
+	case config.MergeQueueOrderUpdateType:
+		return updateTypeRank(renovate.Parse(mr.Title, mr.Description))
+	case config.MergeQueueOrderSmallestDiff:
+		return diffSize(repo, mr, client)
+	default:
+		return 0
+	}
+}
+
+// updateTypeRank ranks an update by its riskiest update type.
+func updateTypeRank(info renovate.Info) int {
+	types := info.UpdateTypes()
+	if len(types) == 0 {
+		return unknownUpdateTypeRank
+	}
+
+	rank := 0
+
+	for _, updateType := range types {
+		r, ok := updateTypeRanks[updateType]
+		if !ok {
+			r = unknownUpdateTypeRank
+		}
+
+		rank = max(rank, r)
+	}
+
+	return rank
+}
+
+// diffSize returns the number of changed files of a merge request.
+func diffSize(repo string, mr *gitlab.BasicMergeRequest, client gl.Client) int {
+	detailed, _, err := client.GetMergeRequest(repo, mr.IID, &gitlab.GetMergeRequestsOptions{})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"repository": repo, "mrID": mr.IID,
+		}).Warn("Failed to get merge request size")
+
+		return math.MaxInt
+	}
+
+	// GitLab caps the count for large diffs, e.g. "1000+".
+	size, err := strconv.Atoi(strings.TrimSuffix(detailed.ChangesCount, "+"))
+	if err != nil {
+		return math.MaxInt
+	}
+
+	return size
+}
+
+func compareAge(a, b *gitlab.BasicMergeRequest) int {
+	if a.CreatedAt != nil && b.CreatedAt != nil {
+		if c := a.CreatedAt.Compare(*b.CreatedAt); c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(a.IID, b.IID)
+}
@@ -0,0 +1,153 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestOrderQueue(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	now := time.Now()
+
+	mrs := []*gitlab.BasicMergeRequest{
+		{IID: 1, Title: "Update dependency foo to v2", CreatedAt: gitlab.Ptr(now.Add(-3 * time.Hour))},
+		{IID: 2, Title: "Update dependency bar to v1.2.4", Description: "| Package | Change |\n|---|---|\n| bar | `1.2.3` -> `1.2.4` |", CreatedAt: gitlab.Ptr(now.Add(-time.Hour))},
+		{IID: 3, Title: "Update dependency baz to v1.3.0", Description: "| Package | Change |\n|---|---|\n| baz | `1.2.3` -> `1.3.0` |", CreatedAt: gitlab.Ptr(now.Add(-2 * time.Hour))},
+		{IID: 4, Title: "Update dependency qux to v1.2.5", Description: "| Package | Change |\n|---|---|\n| qux | `1.2.3` -> `1.2.5` |", CreatedAt: gitlab.Ptr(now.Add(-4 * time.Hour))},
+	}
+
+	tests := []struct {
+		name     string
+		order    string
+		changes  map[int64]string
+		expected []int64
+	}{
+		{
+			name:     "Oldest first",
+			order:    config.MergeQueueOrderOldest,
+			expected: []int64{4, 1, 3, 2},
+		},
+		{
+			name:     "Patch before minor before major",
+			order:    config.MergeQueueOrderUpdateType,
+			expected: []int64{4, 2, 3, 1},
+		},
+		{
+			name:     "Smallest diff first",
+			order:    config.MergeQueueOrderSmallestDiff,
+			changes:  map[int64]string{1: "1", 2: "1000+", 3: "2", 4: "1"},
+			expected: []int64{4, 1, 3, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			for iid, changes := range tt.changes {
+				mockClient.On("GetMergeRequest", repo, iid, mock.Anything).Return(&gitlab.MergeRequest{ChangesCount: changes}, nil)
+			}
+
+			sorted := orderQueue(tt.order, repo, mrs, mockClient)
+
+			iids := make([]int64, 0, len(sorted))
+			for _, mr := range sorted {
+				iids = append(iids, mr.IID)
+			}
+
+			assert.Equal(t, tt.expected, iids)
+		})
+	}
+}
+
+func TestMergeNext(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	cfg := config.Config{MergeQueueOrder: config.MergeQueueOrderOldest}
+	stuckSince := time.Now().Add(-2 * settleTimeout)
+	candidates := []*candidate{
+		{mr: &gitlab.BasicMergeRequest{IID: 5, SHA: "five"}},
+		{mr: &gitlab.BasicMergeRequest{IID: 3, SHA: "three"}},
+	}
+
+	tests := []struct {
+		name       string
+		candidates []*candidate
+		rejections []*rejection
+		mergeErr   error
+		expectIID  int64
+	}{
+		{
+			name:       "Merges the first MR in queue order",
+			candidates: candidates,
+			rejections: []*rejection{{mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterRebase, err: errBehindTarget, outcome: outcomeWaitingRebase}},
+			expectIID:  3,
+		},
+		{
+			name:       "Waits for a pipeline of a rebased MR",
+			candidates: candidates,
+			rejections: []*rejection{{mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterPipeline, err: errPipelineRunning, outcome: outcomeWaitingPipeline}},
+		},
+		{
+			name:       "Doesn't wait for an MR without pipeline",
+			candidates: candidates,
+			rejections: []*rejection{{mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterPipeline, err: errNoPipeline, outcome: outcomeWaitingPipeline}},
+			expectIID:  3,
+		},
+		{
+			name:       "Doesn't wait for a stuck pipeline",
+			candidates: candidates,
+			rejections: []*rejection{{
+				mr: &gitlab.BasicMergeRequest{IID: 6}, pipeline: &gitlab.Pipeline{ID: 60, Status: "created", CreatedAt: &stuckSince},
+				filter: filterPipeline, err: errPipelineRunning, outcome: outcomeWaitingPipeline,
+			}},
+			expectIID: 3,
+		},
+		{
+			name:       "Waits for a rebase in progress",
+			candidates: candidates,
+			rejections: []*rejection{{mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterRebase, err: errRebaseInProgress, outcome: outcomeWaitingRebase}},
+		},
+		{
+			name: "Nothing to merge",
+		},
+		{
+			name:       "Merge fails",
+			candidates: candidates,
+			mergeErr:   errors.New("branch cannot be merged"),
+			expectIID:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("AcceptMergeRequest", repo, mock.Anything, mock.Anything).Return(&gitlab.MergeRequest{}, tt.mergeErr).Maybe()
+
+			err := mergeNext(cfg, repo, tt.candidates, tt.rejections, mockClient)
+			assert.ErrorIs(t, err, tt.mergeErr)
+
+			if tt.expectIID == 0 {
+				mockClient.AssertNotCalled(t, "AcceptMergeRequest", mock.Anything, mock.Anything, mock.Anything)
+
+				return
+			}
+
+			mockClient.AssertNumberOfCalls(t, "AcceptMergeRequest", 1)
+			mockClient.AssertCalled(t, "AcceptMergeRequest", repo, tt.expectIID, &gitlab.AcceptMergeRequestOptions{SHA: gitlab.Ptr("three")})
+		})
+	}
+}
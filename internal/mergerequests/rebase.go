@@ -0,0 +1,108 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const mergeStatusNeedRebase = "need_rebase"
+
+// Reasons for holding back a merge request that is behind its target branch.
+var (
+	errBehindTarget     = errors.New("merge request is behind the target branch")
+	errRebaseInProgress = errors.New("merge request is being rebased")
+)
+
+// checkRebase fetches the merge request to detect whether it diverged from its target branch.
+func checkRebase(repo string, mr *gitlab.BasicMergeRequest, client gl.Client) error {
+	detailed, _, err := client.GetMergeRequest(repo, mr.IID, &gitlab.GetMergeRequestsOptions{
+		IncludeDivergedCommitsCount: gitlab.Ptr(true),
+		IncludeRebaseInProgress:     gitlab.Ptr(true),
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"repository": repo, "mr_id": mr.IID,
+		}).Error("Failed to get merge request")
+
+		return err
+	}
+
+	switch {
+	case detailed.RebaseInProgress:
+		return errRebaseInProgress
+	case detailed.DivergedCommitsCount > 0:
+		return fmt.Errorf("%w by %d commits", errBehindTarget, detailed.DivergedCommitsCount)
+	case detailed.DetailedMergeStatus == mergeStatusNeedRebase:
+		return errBehindTarget
+	}
+
+	return nil
+}
+
+// rebaseFilter holds back merge requests that are behind their target branch.
+type rebaseFilter struct{}
+
+func (rebaseFilter) Name() string     { return filterRebase }
+func (rebaseFilter) Cost() FilterCost { return CostAPI }
+
+func (rebaseFilter) Enabled(config config.Config) bool {
+	return config.RebaseBehindTarget
+}
+
+func (rebaseFilter) Evaluate(fc *FilterContext) Verdict {
+	if err := checkRebase(fc.Repo, fc.MR, fc.Client); err != nil {
+		return failed(err, nil)
+	}
+
+	return passed(nil)
+}
+
+func (rebaseFilter) classify(r *rejection) {
+	r.reportable = errors.Is(r.err, errBehindTarget) || errors.Is(r.err, errRebaseInProgress)
+	r.outcome = outcomeWaitingRebase
+}
+
+// rebaseNext rebases the first merge request in queue order that is behind its target branch.
+// Rebases are serialized per project: nothing is rebased while another MR of the
+// project is being rebased or runs its pipeline, so pipelines don't pile up. An MR without a pipeline,
+// e.g. because CI was skipped, doesn't hold back the rebases, see unsettled.
+// It returns an error if the rebase failed.
+func rebaseNext(config config.Config, repo string, rejections []*rejection, client gl.Client) error {
+	if r := unsettled(rejections); r != nil {
+		logrus.WithFields(logrus.Fields{
+			"repository": repo, "mrID": r.mr.IID,
+		}).Debug("Waiting for MR to settle before rebasing another one")
+
+		return nil
+	}
+
+	var behind []*gitlab.BasicMergeRequest
+
+	for _, r := range rejections {
+		if errors.Is(r.err, errBehindTarget) {
+			behind = append(behind, r.mr)
+		}
+	}
+
+	if len(behind) == 0 {
+		return nil
+	}
+
+	next := orderQueue(config.MergeQueueOrder, repo, behind, client)[0]
+	fields := logrus.Fields{"repository": repo, "mrID": next.IID}
+
+	if _, err := client.RebaseMergeRequest(repo, next.IID, &gitlab.RebaseMergeRequestOptions{}); err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to rebase merge request")
+
+		return err
+	}
+
+	logrus.WithFields(fields).Info("Rebased MR")
+
+	return nil
+}
@@ -0,0 +1,118 @@
+//nolint:lll,funlen
+package mergerequests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestCheckRebase(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	mr := &gitlab.BasicMergeRequest{IID: 1}
+
+	tests := []struct {
+		name     string
+		detailed *gitlab.MergeRequest
+		expected error
+	}{
+		{name: "Up to date", detailed: &gitlab.MergeRequest{}},
+		{name: "Diverged", detailed: &gitlab.MergeRequest{DivergedCommitsCount: 3}, expected: errBehindTarget},
+		{name: "Needs rebase", detailed: &gitlab.MergeRequest{BasicMergeRequest: gitlab.BasicMergeRequest{DetailedMergeStatus: "need_rebase"}}, expected: errBehindTarget},
+		{name: "Rebase in progress", detailed: &gitlab.MergeRequest{RebaseInProgress: true, DivergedCommitsCount: 3}, expected: errRebaseInProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("GetMergeRequest", repo, mr.IID, mock.Anything).Return(tt.detailed, nil)
+
+			err := checkRebase(repo, mr, mockClient)
+			if tt.expected == nil {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRebaseNext(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+
+	behind := func(iid int64) *rejection {
+		return &rejection{mr: &gitlab.BasicMergeRequest{IID: iid}, filter: filterRebase, err: fmt.Errorf("%w by 2 commits", errBehindTarget), outcome: outcomeWaitingRebase}
+	}
+
+	tests := []struct {
+		name       string
+		rejections []*rejection
+		expectIID  int64
+	}{
+		{
+			name:       "Oldest MR is rebased first",
+			rejections: []*rejection{behind(5), behind(3), behind(4)},
+			expectIID:  3,
+		},
+		{
+			name: "Waits for a running pipeline",
+			rejections: []*rejection{
+				behind(3),
+				{mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterPipeline, err: errPipelineRunning, outcome: outcomeWaitingPipeline},
+			},
+		},
+		{
+			name:       "Doesn't wait for an MR without pipeline",
+			rejections: []*rejection{behind(3), {mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterPipeline, err: errNoPipeline, outcome: outcomeWaitingPipeline}},
+			expectIID:  3,
+		},
+		{
+			name: "Doesn't wait for a stuck pipeline",
+			rejections: []*rejection{
+				behind(3),
+				{mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterPipeline, err: errPipelineRunning, outcome: outcomeWaitingPipeline, pipeline: &gitlab.Pipeline{CreatedAt: gitlab.Ptr(time.Now().Add(-2 * settleTimeout))}},
+			},
+			expectIID: 3,
+		},
+		{
+			name:       "Waits for a rebase in progress",
+			rejections: []*rejection{behind(3), {mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterRebase, err: errRebaseInProgress, outcome: outcomeWaitingRebase}},
+		},
+		{
+			name:       "Nothing to rebase",
+			rejections: []*rejection{{mr: &gitlab.BasicMergeRequest{IID: 6}, filter: filterHold, err: errOnHold, outcome: outcomeBlockedPolicy}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("RebaseMergeRequest", repo, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			require.NoError(t, rebaseNext(config.Config{MergeQueueOrder: config.MergeQueueOrderOldest}, repo, tt.rejections, mockClient))
+
+			if tt.expectIID == 0 {
+				mockClient.AssertNotCalled(t, "RebaseMergeRequest", mock.Anything, mock.Anything, mock.Anything)
+
+				return
+			}
+
+			mockClient.AssertNumberOfCalls(t, "RebaseMergeRequest", 1)
+			mockClient.AssertCalled(t, "RebaseMergeRequest", repo, tt.expectIID, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package mergerequests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const retriesAttribute = "retries"
+
+// isRetryableFailure reports whether a pipeline rejection is caused by failed jobs.
+func isRetryableFailure(err error) bool {
+	return errors.Is(err, errPipelineNotSucceeded) ||
+		errors.Is(err, errPipelineWarnings) ||
+		errors.Is(err, errJobFailed)
+}
+
+// retryPipeline retries the failed jobs of a rejected merge request's pipeline.
+// The number of retries per head commit is kept in the state, reported in a note and limited by the retry budget.
+// It returns an error if the retry itself failed.
+func retryPipeline(
+	config config.Config, repo string, r *rejection, user *gitlab.User, client gl.Client, store *state.Store,
+) error {
+	fields := logrus.Fields{"repository": repo, "mrID": r.mr.IID, "pipeline_id": r.pipeline.ID}
+
+	note, err := postedNote(repo, r.mr.IID, noteKindRetry, user, client, store)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to list merge request notes")
+
+		return nil
+	}
+
+	mr := store.MergeRequest(repo, r.mr.IID)
+
+	retries := 0
+	if mr.RetriedSHA == r.mr.SHA {
+		retries = mr.Retries
+	}
+
+	if retries >= config.RetryBudget {
+		logrus.WithFields(fields).Debug("Retry budget exhausted")
+
+		return nil
+	}
+
+	jobs, err := retryableJobs(config, repo, r.pipeline, client)
+	if err != nil || len(jobs) == 0 {
+		logrus.WithFields(fields).Debug("No retryable jobs")
+
+		return nil
+	}
+
+	if err := retryJobs(config, repo, r.pipeline, jobs, client); err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to retry pipeline")
+
+		return err
+	}
+
+	retries++
+	mr.Retries, mr.RetriedSHA = retries, r.mr.SHA
+	store.SetMergeRequest(repo, r.mr.IID, mr)
+
+	names := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		names = append(names, "`"+job.Name+"`")
+	}
+
+	// The marker keeps the count for runs without a persistent state.
+	body := withNoteMarker(
+		fmt.Sprintf(":repeat: renoglaab retried %s of pipeline #%d (retry %d of %d).",
+			strings.Join(names, ", "), r.pipeline.ID, retries, config.RetryBudget),
+		noteKindRetry, r.mr.SHA, retriesAttribute+"="+strconv.Itoa(retries),
+	)
+
+	if err := writeNote(repo, r.mr.IID, noteKindRetry, r.mr.SHA, body, note, client, store); err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to record pipeline retry")
+	}
+
+	logrus.WithFields(fields).WithField("retries", retries).Info("Retried pipeline")
+
+	return nil
+}
+
+// retryableJobs returns the failed jobs of a pipeline if all of them qualify for a retry.
+// Without failure reasons or log patterns every failed job qualifies.
+func retryableJobs(config config.Config, repo string, pipeline *gitlab.Pipeline, client gl.Client) ([]*gitlab.Job, error) {
+	jobs, err := listPipelineJobs(client, repo, pipeline.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []*gitlab.Job
+
+	for _, job := range jobs {
+		if !blocksPipeline(config, pipeline.ID, job) {
+			continue
+		}
+
+		if !isRetryableJob(config, repo, job, client) {
+			logrus.WithFields(logrus.Fields{
+				"repository": repo, "job": job.Name, "failure_reason": job.FailureReason,
+			}).Debug("Job failure does not qualify for a retry")
+
+			return nil, nil
+		}
+
+		failed = append(failed, job)
+	}
+
+	return failed, nil
+}
+
+// blocksPipeline reports whether a job failed in a way that rejects its pipeline. With FILTER_BY_JOBS
+// the job rules decide, so a job allowed to fail doesn't stand in the way of a retry.
+func blocksPipeline(config config.Config, pipelineID int64, job *gitlab.Job) bool {
+	switch {
+	case job.Status != jobStatusFailed:
+		return false
+	case config.FilterByJobs:
+		return checkJob(config, pipelineID, job) != nil
+	default:
+		return !job.AllowFailure || config.FilterByPipelineWithoutWarnings
+	}
+}
+
+// isRetryableJob checks the failure reason and log of a failed job against the retry rules.
+func isRetryableJob(config config.Config, repo string, job *gitlab.Job, client gl.Client) bool {
+	if len(config.RetryFailureReasons) == 0 && len(config.RetryLogPatternsCompiled) == 0 {
+		return true
+	}
+
+	if slices.Contains(config.RetryFailureReasons, job.FailureReason) {
+		return true
+	}
+
+	if len(config.RetryLogPatternsCompiled) == 0 {
+		return false
+	}
+
+	trace, _, err := client.GetJobTrace(repo, job.ID)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"repository": repo, "job": job.Name}).Warn("Failed to fetch job log")
+
+		return false
+	}
+
+	log, err := io.ReadAll(trace)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range config.RetryLogPatternsCompiled {
+		if pattern.Match(log) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryJobs retries the whole pipeline unless retry rules select individual jobs.
+func retryJobs(config config.Config, repo string, pipeline *gitlab.Pipeline, jobs []*gitlab.Job, client gl.Client) error {
+	if len(config.RetryFailureReasons) == 0 && len(config.RetryLogPatternsCompiled) == 0 {
+		_, _, err := client.RetryPipeline(repo, pipeline.ID)
+
+		return err
+	}
+
+	for _, job := range jobs {
+		if _, _, err := client.RetryJob(repo, job.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+//nolint:lll,funlen
+package mergerequests
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestRetryPipeline(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "abc"}
+	pipeline := &gitlab.Pipeline{ID: 100, Status: "failed"}
+
+	retryNote := func(sha, retries string) *gitlab.Note {
+		return &gitlab.Note{ID: 7, Body: withNoteMarker("retried", noteKindRetry, sha, "retries="+retries), Author: gitlab.NoteAuthor{ID: 42}}
+	}
+
+	infraFailure := &gitlab.Job{ID: 1, Name: "test", Status: "failed", FailureReason: "runner_system_failure"}
+	scriptFailure := &gitlab.Job{ID: 2, Name: "lint", Status: "failed", FailureReason: "script_failure"}
+	flakyFailure := &gitlab.Job{ID: 3, Name: "e2e", Status: "failed", FailureReason: "script_failure"}
+
+	tests := []struct {
+		name                string
+		cfg                 config.Config
+		state               *state.MergeRequest
+		notes               []*gitlab.Note
+		jobs                []*gitlab.Job
+		expectPipelineRetry int
+		expectJobRetries    int
+		expectCreate        int
+		expectUpdate        int
+		expectRetries       int
+	}{
+		{
+			name:                "Retry whole pipeline without rules",
+			cfg:                 config.Config{RetryBudget: 2},
+			jobs:                []*gitlab.Job{scriptFailure},
+			expectPipelineRetry: 1,
+			expectCreate:        1,
+			expectRetries:       1,
+		},
+		{
+			name:                "Budget left for current head",
+			cfg:                 config.Config{RetryBudget: 2},
+			notes:               []*gitlab.Note{retryNote("abc", "1")},
+			jobs:                []*gitlab.Job{scriptFailure},
+			expectPipelineRetry: 1,
+			expectUpdate:        1,
+			expectRetries:       2,
+		},
+		{
+			name:          "Budget exhausted",
+			cfg:           config.Config{RetryBudget: 2},
+			notes:         []*gitlab.Note{retryNote("abc", "2")},
+			jobs:          []*gitlab.Job{scriptFailure},
+			expectRetries: 2,
+		},
+		{
+			name:          "Budget exhausted in the state",
+			cfg:           config.Config{RetryBudget: 2},
+			state:         &state.MergeRequest{Retries: 2, RetriedSHA: "abc", Notes: map[string]state.Note{string(noteKindRetry): {ID: 7, SHA: "abc"}}},
+			jobs:          []*gitlab.Job{scriptFailure},
+			expectRetries: 2,
+		},
+		{
+			name:                "Budget resets for new head",
+			cfg:                 config.Config{RetryBudget: 2},
+			notes:               []*gitlab.Note{retryNote("old", "2")},
+			jobs:                []*gitlab.Job{scriptFailure},
+			expectPipelineRetry: 1,
+			expectUpdate:        1,
+			expectRetries:       1,
+		},
+		{
+			name:             "Retry jobs with matching failure reason",
+			cfg:              config.Config{RetryBudget: 2, RetryFailureReasons: []string{"runner_system_failure"}},
+			jobs:             []*gitlab.Job{infraFailure, {Name: "build", Status: "success"}},
+			expectJobRetries: 1,
+			expectCreate:     1,
+			expectRetries:    1,
+		},
+		{
+			name: "Real failure is not retried",
+			cfg:  config.Config{RetryBudget: 2, RetryFailureReasons: []string{"runner_system_failure"}},
+			jobs: []*gitlab.Job{infraFailure, scriptFailure},
+		},
+		{
+			name:             "Job allowed to fail is not in the way",
+			cfg:              config.Config{RetryBudget: 2, RetryFailureReasons: []string{"runner_system_failure"}, FilterByJobs: true, AllowedFailureJobs: []string{"lint"}},
+			jobs:             []*gitlab.Job{infraFailure, scriptFailure},
+			expectJobRetries: 1,
+			expectCreate:     1,
+			expectRetries:    1,
+		},
+		{
+			name:             "Retry jobs with matching log",
+			cfg:              config.Config{RetryBudget: 2, RetryLogPatternsCompiled: []*regexp.Regexp{regexp.MustCompile(`connection reset by peer`)}},
+			jobs:             []*gitlab.Job{flakyFailure},
+			expectJobRetries: 1,
+			expectCreate:     1,
+			expectRetries:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return(tt.notes, nil).Maybe()
+			mockClient.On("ListPipelineJobs", repo, pipeline.ID, mock.Anything).Return(tt.jobs, nil).Maybe()
+			mockClient.On("GetJobTrace", repo, flakyFailure.ID).Return("dial tcp: connection reset by peer", nil).Maybe()
+			mockClient.On("RetryPipeline", repo, pipeline.ID).Return(pipeline, nil).Maybe()
+			mockClient.On("RetryJob", repo, mock.Anything).Return(&gitlab.Job{}, nil).Maybe()
+			mockClient.On("CreateMergeRequestNote", repo, mr.IID, mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+			mockClient.On("UpdateMergeRequestNote", repo, mr.IID, int64(7), mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+
+			store := newMemoryStore(t)
+			if tt.state != nil {
+				store.SetMergeRequest(repo, mr.IID, *tt.state)
+			}
+
+			require.NoError(t, retryPipeline(tt.cfg, repo, &rejection{mr: mr, pipeline: pipeline, retryable: true}, user, mockClient, store))
+
+			if tt.state != nil {
+				mockClient.AssertNotCalled(t, "ListMergeRequestNotes", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			assert.Equal(t, tt.expectRetries, store.MergeRequest(repo, mr.IID).Retries)
+
+			mockClient.AssertNumberOfCalls(t, "RetryPipeline", tt.expectPipelineRetry)
+			mockClient.AssertNumberOfCalls(t, "RetryJob", tt.expectJobRetries)
+			mockClient.AssertNumberOfCalls(t, "CreateMergeRequestNote", tt.expectCreate)
+			mockClient.AssertNumberOfCalls(t, "UpdateMergeRequestNote", tt.expectUpdate)
+		})
+	}
+}
+
+func TestNoteMarkerAttribute(t *testing.T) {
+	t.Parallel()
+
+	body := withNoteMarker("retried", noteKindRetry, "abc", "retries=2")
+
+	value, ok := noteMarkerAttribute(body, retriesAttribute)
+	assert.True(t, ok)
+	assert.Equal(t, "2", value)
+
+	kind, sha, ok := parseNoteMarker(body)
+	assert.True(t, ok)
+	assert.Equal(t, noteKindRetry, kind)
+	assert.Equal(t, "abc", sha)
+
+	_, ok = noteMarkerAttribute(withNoteMarker("approved", noteKindApproval, "abc"), retriesAttribute)
+	assert.False(t, ok)
+}
@@ -0,0 +1,117 @@
+package mergerequests
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+)
+
+// VerdictStatus is the result of a single filter.
+type VerdictStatus string
+
+// Results of a filter. A filter is skipped if it is disabled, or if the merge request was
+// already rejected by an earlier filter and only the first rejection was needed.
+const (
+	VerdictPassed  VerdictStatus = "passed"
+	VerdictFailed  VerdictStatus = "failed"
+	VerdictSkipped VerdictStatus = "skipped"
+)
+
+// Evidence holds the data a verdict is based on, e.g. the ID and icon of the checked pipeline.
+type Evidence map[string]any
+
+// Verdict is the result of a single filter for a merge request.
+type Verdict struct {
+	Filter   string        `json:"filter"`
+	Status   VerdictStatus `json:"status"`
+	Reason   string        `json:"reason,omitempty"`
+	Evidence Evidence      `json:"evidence,omitempty"`
+	// Err is the error a failed verdict is based on.
+	Err error `json:"-"`
+}
+
+// Trace is the decision trace of a merge request: the verdict of every filter, in the order they were checked.
+type Trace struct {
+	Repository string    `json:"repository"`
+	IID        int64     `json:"iid"`
+	Title      string    `json:"title"`
+	SHA        string    `json:"sha"`
+	Approvable bool      `json:"approvable"`
+	RejectedBy string    `json:"rejected_by,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Verdicts   []Verdict `json:"verdicts"`
+	// PolicyInput is the input POLICY was evaluated against, to record it as a policy test fixture.
+	PolicyInput *PolicyInput `json:"policy_input,omitempty"`
+	// OPAInput is the input document sent to OPA, to replay the decision with opa eval.
+	OPAInput *OPAInput `json:"opa_input,omitempty"`
+}
+
+// add appends a verdict and logs it.
+func (t *Trace) add(v Verdict) {
+	t.Verdicts = append(t.Verdicts, v)
+
+	logrus.WithFields(logrus.Fields{
+		"repository": t.Repository, "mr_id": t.IID, "filter": v.Filter, "status": v.Status,
+		"reason": v.Reason, "evidence": v.Evidence,
+	}).Debug("Filter verdict")
+}
+
+// passed returns the filters the merge request passed.
+func (t *Trace) passed() []string {
+	var filters []string
+
+	for _, v := range t.Verdicts {
+		if v.Status == VerdictPassed {
+			filters = append(filters, v.Filter)
+		}
+	}
+
+	return filters
+}
+
+// WriteText writes the trace in a human-readable form.
+func (t *Trace) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s!%d %s (%s)\n", t.Repository, t.IID, t.Title, t.SHA); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, v := range t.Verdicts {
+		details := v.Reason
+		if len(v.Evidence) > 0 {
+			details = strings.TrimSpace(details + " [" + v.Evidence.String() + "]")
+		}
+
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\n", v.Status, v.Filter, details)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	verdict := "Approvable"
+	if !t.Approvable {
+		verdict = fmt.Sprintf("Not approvable, rejected by %s: %s", t.RejectedBy, t.Reason)
+	}
+
+	_, err := fmt.Fprintln(w, verdict)
+
+	return err
+}
+
+// String formats the evidence as sorted key=value pairs.
+func (e Evidence) String() string {
+	pairs := make([]string, 0, len(e))
+
+	for _, key := range slices.Sorted(maps.Keys(e)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, e[key]))
+	}
+
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,102 @@
+//nolint:lll,funlen,err113
+package mergerequests
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestShouldProcessMRTrace(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	cfg := config.Config{
+		FilterByLabels:                  true,
+		Labels:                          []string{"renovate"},
+		FilterByBranch:                  true,
+		AllowedBranchRegexCompiled:      regexp.MustCompile("^renovate/.*$"),
+		FilterBySucceededPipeline:       true,
+		FilterByPipelineWithoutWarnings: true,
+	}
+
+	mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "abc", Title: "Update foo", SourceBranch: "renovate/foo"}
+	pipeline := &gitlab.Pipeline{ID: 100, Status: "success", WebURL: "https://gitlab.com/test/repo/-/pipelines/100", DetailedStatus: &gitlab.DetailedStatus{Icon: "status_warning"}}
+
+	mockClient := new(MockGitLabClient)
+	mockClient.On("ListProjectPipelines", repo, mock.Anything).Return([]*gitlab.PipelineInfo{{ID: 100}}, nil)
+	mockClient.On("GetPipeline", repo, int64(100)).Return(pipeline, nil)
+
+	_, r := shouldProcessMR(repo, mr, cfg, mockClient)
+	require.NotNil(t, r)
+	require.ErrorIs(t, r.err, errPipelineWarnings)
+	assert.Equal(t, pipeline, r.pipeline)
+
+	failedVerdict := &r.trace.Verdicts[len(r.trace.Verdicts)-3]
+	require.ErrorIs(t, failedVerdict.Err, errPipelineWarnings)
+
+	failedVerdict.Err = nil
+
+	assert.Equal(t, &Trace{
+		Repository: repo, IID: 1, Title: "Update foo", SHA: "abc",
+		RejectedBy: filterPipeline, Reason: "pipeline succeeded with warnings: pipeline #100",
+		Verdicts: []Verdict{
+			{Filter: filterState, Status: VerdictPassed, Reason: "filtered when listing"},
+			{Filter: filterAuthor, Status: VerdictSkipped, Reason: "disabled"},
+			{Filter: filterLabels, Status: VerdictPassed, Reason: "filtered when listing"},
+			{Filter: filterBranch, Status: VerdictPassed, Evidence: Evidence{"branch": "renovate/foo", "regex": "^renovate/.*$"}},
+			{Filter: filterExcludeLabels, Status: VerdictSkipped, Reason: "disabled"},
+			{Filter: filterMergeability, Status: VerdictSkipped, Reason: "disabled"},
+			{Filter: filterHold, Status: VerdictSkipped, Reason: "disabled"},
+			{Filter: filterRebase, Status: VerdictSkipped, Reason: "disabled"},
+			{Filter: filterPipeline, Status: VerdictFailed, Reason: "pipeline succeeded with warnings: pipeline #100", Evidence: Evidence{
+				"pipeline_id": int64(100), "status": "success", "icon": "status_warning", "web_url": "https://gitlab.com/test/repo/-/pipelines/100",
+			}},
+			{Filter: filterPolicy, Status: VerdictSkipped, Reason: "disabled"},
+			{Filter: filterOPA, Status: VerdictSkipped, Reason: "disabled"},
+		},
+	}, r.trace)
+
+	// Later filters are skipped once the MR is rejected.
+	_, r = shouldProcessMR(repo, &gitlab.BasicMergeRequest{IID: 2, SourceBranch: "feature"}, cfg, mockClient)
+	require.NotNil(t, r)
+	assert.Equal(t, Verdict{Filter: filterPipeline, Status: VerdictSkipped, Reason: "not checked after branch failed"}, r.trace.Verdicts[len(r.trace.Verdicts)-3])
+	mockClient.AssertNumberOfCalls(t, "GetPipeline", 1)
+}
+
+func TestTraceOutput(t *testing.T) {
+	t.Parallel()
+
+	trace := &Trace{
+		Repository: "test/repo", IID: 1, Title: "Update foo", SHA: "abc",
+		RejectedBy: filterPipeline, Reason: "pipeline did not succeed: pipeline #100 is failed",
+		Verdicts: []Verdict{
+			{Filter: filterBranch, Status: VerdictPassed, Evidence: Evidence{"regex": "^renovate/.*$", "branch": "renovate/foo"}},
+			{Filter: filterHold, Status: VerdictSkipped, Reason: "disabled"},
+			{Filter: filterPipeline, Status: VerdictFailed, Reason: "pipeline did not succeed: pipeline #100 is failed", Evidence: Evidence{"pipeline_id": 100, "icon": "status_failed"}},
+		},
+	}
+
+	var text strings.Builder
+	require.NoError(t, trace.WriteText(&text))
+	assert.Equal(t, `test/repo!1 Update foo (abc)
+  passed   branch    [branch=renovate/foo regex=^renovate/.*$]
+  skipped  hold      disabled
+  failed   pipeline  pipeline did not succeed: pipeline #100 is failed [icon=status_failed pipeline_id=100]
+Not approvable, rejected by pipeline: pipeline did not succeed: pipeline #100 is failed
+`, text.String())
+
+	encoded, err := json.Marshal(trace.Verdicts[1:])
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"filter": "hold", "status": "skipped", "reason": "disabled"},
+		{"filter": "pipeline", "status": "failed", "reason": "pipeline did not succeed: pipeline #100 is failed", "evidence": {"pipeline_id": 100, "icon": "status_failed"}}
+	]`, string(encoded))
+}
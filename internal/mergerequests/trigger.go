@@ -0,0 +1,64 @@
+package mergerequests
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const triggeredAtAttribute = "triggered_at"
+
+// triggerPipeline creates a branch pipeline for a merge request without a pipeline for its head commit.
+// The last trigger is kept in the state and reported in a note, so a pipeline is triggered at most once per cooldown.
+// It returns an error if the pipeline could not be created.
+func triggerPipeline(
+	config config.Config, repo string, r *rejection, user *gitlab.User, client gl.Client, store *state.Store,
+) error {
+	fields := logrus.Fields{"repository": repo, "mrID": r.mr.IID, "branch": r.mr.SourceBranch, "sha": r.mr.SHA}
+
+	note, err := postedNote(repo, r.mr.IID, noteKindTrigger, user, client, store)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to list merge request notes")
+
+		return nil
+	}
+
+	mr := store.MergeRequest(repo, r.mr.IID)
+
+	if mr.TriggeredSHA == r.mr.SHA && time.Since(mr.TriggeredAt) < config.TriggerPipelineCooldown {
+		logrus.WithFields(fields).Debug("Pipeline trigger is cooling down")
+
+		return nil
+	}
+
+	pipeline, _, err := client.CreatePipeline(repo, &gitlab.CreatePipelineOptions{Ref: gitlab.Ptr(r.mr.SourceBranch)})
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to trigger pipeline")
+
+		return err
+	}
+
+	now := time.Now()
+	mr.TriggeredAt, mr.TriggeredSHA = now, r.mr.SHA
+	store.SetMergeRequest(repo, r.mr.IID, mr)
+
+	// The marker keeps the time for runs without a persistent state.
+	body := withNoteMarker(
+		fmt.Sprintf(":arrow_forward: renoglaab triggered pipeline #%d because there was none for %s.", pipeline.ID, r.mr.SHA),
+		noteKindTrigger, r.mr.SHA, triggeredAtAttribute+"="+strconv.FormatInt(now.Unix(), 10),
+	)
+
+	if err := writeNote(repo, r.mr.IID, noteKindTrigger, r.mr.SHA, body, note, client, store); err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to record pipeline trigger")
+	}
+
+	logrus.WithFields(fields).WithField("pipeline_id", pipeline.ID).Info("Triggered pipeline")
+
+	return nil
+}
@@ -0,0 +1,101 @@
+//nolint:lll,funlen
+package mergerequests
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/config"
+	"github.com/xMoelletschi/renoglaab/internal/state"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestTriggerPipeline(t *testing.T) {
+	t.Parallel()
+
+	repo := "test/repo"
+	user := &gitlab.User{ID: 42}
+	mr := &gitlab.BasicMergeRequest{IID: 1, SHA: "abc", SourceBranch: "renovate/automerge"}
+	cfg := config.Config{TriggerPipelineCooldown: time.Hour}
+
+	triggerNote := func(sha string, at time.Time) *gitlab.Note {
+		return &gitlab.Note{
+			ID:     7,
+			Body:   withNoteMarker("triggered", noteKindTrigger, sha, triggeredAtAttribute+"="+strconv.FormatInt(at.Unix(), 10)),
+			Author: gitlab.NoteAuthor{ID: 42},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		state         *state.MergeRequest
+		notes         []*gitlab.Note
+		expectTrigger int
+		expectCreate  int
+		expectUpdate  int
+	}{
+		{
+			name:          "First trigger",
+			expectTrigger: 1,
+			expectCreate:  1,
+		},
+		{
+			name:  "Cooling down",
+			notes: []*gitlab.Note{triggerNote("abc", time.Now().Add(-time.Minute))},
+		},
+		{
+			name: "Cooling down in the state",
+			state: &state.MergeRequest{
+				TriggeredAt: time.Now().Add(-time.Minute), TriggeredSHA: "abc",
+				Notes: map[string]state.Note{string(noteKindTrigger): {ID: 7, SHA: "abc"}},
+			},
+		},
+		{
+			name:          "Cooldown expired",
+			notes:         []*gitlab.Note{triggerNote("abc", time.Now().Add(-2*time.Hour))},
+			expectTrigger: 1,
+			expectUpdate:  1,
+		},
+		{
+			name:          "New head commit",
+			notes:         []*gitlab.Note{triggerNote("old", time.Now().Add(-time.Minute))},
+			expectTrigger: 1,
+			expectUpdate:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := new(MockGitLabClient)
+			mockClient.On("ListMergeRequestNotes", repo, mr.IID, mock.Anything).Return(tt.notes, nil).Maybe()
+			mockClient.On("CreatePipeline", repo, &gitlab.CreatePipelineOptions{Ref: gitlab.Ptr(mr.SourceBranch)}).Return(&gitlab.Pipeline{ID: 100}, nil).Maybe()
+			mockClient.On("CreateMergeRequestNote", repo, mr.IID, mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+			mockClient.On("UpdateMergeRequestNote", repo, mr.IID, int64(7), mock.Anything).Return(&gitlab.Note{}, nil).Maybe()
+
+			store := newMemoryStore(t)
+			if tt.state != nil {
+				store.SetMergeRequest(repo, mr.IID, *tt.state)
+			}
+
+			require.NoError(t, triggerPipeline(cfg, repo, &rejection{mr: mr, triggerable: true}, user, mockClient, store))
+
+			if tt.state != nil {
+				mockClient.AssertNotCalled(t, "ListMergeRequestNotes", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			if tt.expectTrigger > 0 {
+				assert.Equal(t, "abc", store.MergeRequest(repo, mr.IID).TriggeredSHA)
+			}
+
+			mockClient.AssertNumberOfCalls(t, "CreatePipeline", tt.expectTrigger)
+			mockClient.AssertNumberOfCalls(t, "CreateMergeRequestNote", tt.expectCreate)
+			mockClient.AssertNumberOfCalls(t, "UpdateMergeRequestNote", tt.expectUpdate)
+		})
+	}
+}
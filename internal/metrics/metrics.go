@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const namespace = "renoglaab"
+
+// Filter results recorded by FilterResults.
+const (
+	ResultPassed   = "passed"
+	ResultRejected = "rejected"
+)
+
+// API request outcomes recorded by APIRequestDuration.
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+// Registry holds all renoglaab metrics. A dedicated registry keeps the
+// exported metrics free of the default Go runtime collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// MergeRequestsEvaluated counts the merge requests checked against the filters.
+	MergeRequestsEvaluated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "merge_requests_evaluated_total",
+		Help:      "Number of merge requests evaluated against the configured filters.",
+	}, []string{"repository"})
+
+	// MergeRequestsApproved counts the merge requests renoglaab acted upon.
+	MergeRequestsApproved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "merge_requests_approved_total",
+		Help:      "Number of merge requests approved.",
+	}, []string{"repository"})
+
+	// MergeRequestsMerged counts the merge requests merged by the merge queue.
+	MergeRequestsMerged = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "merge_requests_merged_total",
+		Help:      "Number of merge requests merged by the merge queue.",
+	}, []string{"repository"})
+
+	// MergeRequestsWaiting tracks the open merge requests that did not qualify in the last run.
+	MergeRequestsWaiting = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "merge_requests_waiting",
+		Help:      "Number of open merge requests that did not qualify for approval in the last run.",
+	}, []string{"repository"})
+
+	// FilterResults counts the outcome of each filter.
+	FilterResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "filter_results_total",
+		Help:      "Number of filter evaluations by filter and result.",
+	}, []string{"filter", "result"})
+
+	// APIRequestDuration observes the latency of GitLab API calls.
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gitlab_api_request_duration_seconds",
+		Help:      "Latency of GitLab API requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "status"})
+
+	// ReconcileDuration observes how long reconciling a repository takes.
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Time spent reconciling the merge requests of a repository.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"repository"})
+
+	// LastRunTimestamp records when the last run finished.
+	LastRunTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix timestamp of the last completed run.",
+	})
+)
+
+//nolint:gochecknoinits
+func init() {
+	Registry.MustRegister(
+		MergeRequestsEvaluated,
+		MergeRequestsApproved,
+		MergeRequestsMerged,
+		MergeRequestsWaiting,
+		FilterResults,
+		APIRequestDuration,
+		ReconcileDuration,
+		LastRunTimestamp,
+	)
+}
+
+// ObserveAPIRequest records the duration of a GitLab API call started at start.
+func ObserveAPIRequest(method string, start time.Time, err error) {
+	status := StatusSuccess
+	if err != nil {
+		status = StatusError
+	}
+
+	APIRequestDuration.WithLabelValues(method, status).Observe(time.Since(start).Seconds())
+}
+
+// RecordFilter records the result of a single filter evaluation.
+func RecordFilter(filter string, passed bool) {
+	result := ResultRejected
+	if passed {
+		result = ResultPassed
+	}
+
+	FilterResults.WithLabelValues(filter, result).Inc()
+}
+
+// Handler returns an HTTP handler exposing the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// WriteToFile writes the metrics in the text exposition format to path,
+// suitable for the node_exporter textfile collector.
+func WriteToFile(path string) error {
+	return prometheus.WriteToTextfile(path, Registry)
+}
+
+// Push sends the metrics to a Prometheus Pushgateway.
+func Push(url string) error {
+	return push.New(url, namespace).Gatherer(Registry).Push()
+}
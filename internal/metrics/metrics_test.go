@@ -0,0 +1,34 @@
+package metrics_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xMoelletschi/renoglaab/internal/metrics"
+)
+
+func TestWriteToFile(t *testing.T) {
+	t.Parallel()
+
+	metrics.RecordFilter("branch", true)
+	metrics.RecordFilter("pipeline", false)
+	metrics.ObserveAPIRequest("GetPipeline", time.Now(), errors.New("boom")) //nolint:err113
+	metrics.MergeRequestsWaiting.WithLabelValues("group/project").Set(3)
+
+	path := filepath.Join(t.TempDir(), "renoglaab.prom")
+	require.NoError(t, metrics.WriteToFile(path))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	output := string(content)
+	assert.Contains(t, output, `renoglaab_filter_results_total{filter="branch",result="passed"}`)
+	assert.Contains(t, output, `renoglaab_filter_results_total{filter="pipeline",result="rejected"}`)
+	assert.Contains(t, output, `renoglaab_gitlab_api_request_duration_seconds_count{method="GetPipeline",status="error"} 1`)
+	assert.Contains(t, output, `renoglaab_merge_requests_waiting{repository="group/project"} 3`)
+}
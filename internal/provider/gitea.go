@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	giteaPageSize       = 50
+	giteaReviewApproved = "APPROVED"
+)
+
+var errUnexpectedStatus = errors.New("unexpected status")
+
+// Gitea implements Provider on top of the Gitea API. It works for Forgejo as well.
+type Gitea struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewGitea returns a Gitea provider for the instance at baseURL.
+func NewGitea(baseURL, token string) *Gitea {
+	return &Gitea{url: strings.TrimSuffix(baseURL, "/"), token: token, client: http.DefaultClient}
+}
+
+type giteaUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+}
+
+type giteaPullRequest struct {
+	Number int64     `json:"number"`
+	Title  string    `json:"title"`
+	Body   string    `json:"body"`
+	User   giteaUser `json:"user"`
+	Draft  bool      `json:"draft"`
+	URL    string    `json:"html_url"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+type giteaCombinedStatus struct {
+	State      string `json:"state"`
+	TotalCount int    `json:"total_count"`
+	Statuses   []struct {
+		TargetURL string `json:"target_url"`
+	} `json:"statuses"`
+}
+
+type giteaReview struct {
+	User      giteaUser `json:"user"`
+	State     string    `json:"state"`
+	CommitID  string    `json:"commit_id"`
+	Stale     bool      `json:"stale"`
+	Dismissed bool      `json:"dismissed"`
+}
+
+type giteaComment struct {
+	ID   int64     `json:"id"`
+	User giteaUser `json:"user"`
+	Body string    `json:"body"`
+}
+
+// Name returns "gitea".
+func (g *Gitea) Name() string {
+	return "gitea"
+}
+
+// CurrentUser returns the user the token belongs to.
+func (g *Gitea) CurrentUser() (*User, error) {
+	var user giteaUser
+	if err := g.do(http.MethodGet, "/user", nil, &user); err != nil {
+		return nil, err
+	}
+
+	return &User{ID: user.ID, Username: user.Login}, nil
+}
+
+// ListChangeRequests lists the open pull requests of a repository.
+// Gitea can't filter by author or label names, so this is done here.
+func (g *Gitea) ListChangeRequests(repo string, opts ListOptions) ([]*ChangeRequest, error) {
+	var changeRequests []*ChangeRequest
+
+	for page := 1; ; page++ {
+		var pulls []giteaPullRequest
+
+		query := url.Values{"state": {"open"}, "page": {strconv.Itoa(page)}, "limit": {strconv.Itoa(giteaPageSize)}}
+		if err := g.do(http.MethodGet, repoPath(repo, "pulls")+"?"+query.Encode(), nil, &pulls); err != nil {
+			return nil, err
+		}
+
+		for _, pull := range pulls {
+			cr := pull.changeRequest()
+			if matches(cr, opts) {
+				changeRequests = append(changeRequests, cr)
+			}
+		}
+
+		if len(pulls) < giteaPageSize {
+			return changeRequests, nil
+		}
+	}
+}
+
+func (p giteaPullRequest) changeRequest() *ChangeRequest {
+	cr := &ChangeRequest{
+		ID:           p.Number,
+		Title:        p.Title,
+		Description:  p.Body,
+		Author:       p.User.Login,
+		SourceBranch: p.Head.Ref,
+		TargetBranch: p.Base.Ref,
+		SHA:          p.Head.SHA,
+		Draft:        p.Draft,
+		WebURL:       p.URL,
+	}
+
+	for _, label := range p.Labels {
+		cr.Labels = append(cr.Labels, label.Name)
+	}
+
+	return cr
+}
+
+func matches(cr *ChangeRequest, opts ListOptions) bool {
+	if opts.Author != "" && cr.Author != opts.Author {
+		return false
+	}
+
+	for _, label := range opts.Labels {
+		if !slices.Contains(cr.Labels, label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckStatus returns the combined commit status of the head commit.
+func (g *Gitea) CheckStatus(repo string, cr *ChangeRequest) (*CheckStatus, error) {
+	var status giteaCombinedStatus
+	if err := g.do(http.MethodGet, repoPath(repo, "commits", cr.SHA, "status"), nil, &status); err != nil {
+		return nil, err
+	}
+
+	if status.TotalCount == 0 {
+		return &CheckStatus{State: CheckNone}, nil
+	}
+
+	result := &CheckStatus{Status: status.State}
+	if len(status.Statuses) > 0 {
+		result.WebURL = status.Statuses[0].TargetURL
+	}
+
+	switch status.State {
+	case "success":
+		result.State = CheckSuccess
+	case "pending":
+		result.State = CheckPending
+	default:
+		result.State = CheckFailure
+	}
+
+	return result, nil
+}
+
+// ListApprovals returns the approving reviews of a pull request.
+// Reviews for an older head commit are stale.
+func (g *Gitea) ListApprovals(repo string, cr *ChangeRequest) ([]Approval, error) {
+	var reviews []giteaReview
+	if err := g.do(http.MethodGet, repoPath(repo, "pulls", strconv.FormatInt(cr.ID, 10), "reviews"), nil, &reviews); err != nil {
+		return nil, err
+	}
+
+	var approvals []Approval
+
+	for _, review := range reviews {
+		if review.State != giteaReviewApproved || review.Dismissed {
+			continue
+		}
+
+		approvals = append(approvals, Approval{
+			User:  User{ID: review.User.ID, Username: review.User.Login},
+			Stale: review.Stale || review.CommitID != cr.SHA,
+		})
+	}
+
+	return approvals, nil
+}
+
+// Approve submits an approving review for the head commit with body as its comment.
+func (g *Gitea) Approve(repo string, cr *ChangeRequest, body string) error {
+	review := map[string]string{"event": giteaReviewApproved, "body": body, "commit_id": cr.SHA}
+
+	return g.do(http.MethodPost, repoPath(repo, "pulls", strconv.FormatInt(cr.ID, 10), "reviews"), review, nil)
+}
+
+// ListComments returns the comments of a pull request, newest first.
+func (g *Gitea) ListComments(repo string, cr *ChangeRequest) ([]*Comment, error) {
+	var comments []giteaComment
+	if err := g.do(http.MethodGet, repoPath(repo, "issues", strconv.FormatInt(cr.ID, 10), "comments"), nil, &comments); err != nil {
+		return nil, err
+	}
+
+	result := make([]*Comment, 0, len(comments))
+	for _, comment := range slices.Backward(comments) {
+		result = append(result, &Comment{ID: comment.ID, Author: User{ID: comment.User.ID, Username: comment.User.Login}, Body: comment.Body})
+	}
+
+	return result, nil
+}
+
+// CreateComment adds a comment to a pull request.
+func (g *Gitea) CreateComment(repo string, cr *ChangeRequest, body string) error {
+	return g.do(http.MethodPost, repoPath(repo, "issues", strconv.FormatInt(cr.ID, 10), "comments"), map[string]string{"body": body}, nil)
+}
+
+// UpdateComment changes a comment of a pull request.
+func (g *Gitea) UpdateComment(repo string, _ *ChangeRequest, commentID int64, body string) error {
+	return g.do(http.MethodPatch, repoPath(repo, "issues", "comments", strconv.FormatInt(commentID, 10)), map[string]string{"body": body}, nil)
+}
+
+// repoPath builds the API path below a repository given as "owner/name".
+func repoPath(repo string, segments ...string) string {
+	path := "/repos"
+	for _, segment := range append(strings.Split(repo, "/"), segments...) {
+		path += "/" + url.PathEscape(segment)
+	}
+
+	return path
+}
+
+// do sends a request to the API, encoding in as the JSON body and decoding the response into out.
+func (g *Gitea) do(method, path string, in, out any) error {
+	var body bytes.Buffer
+
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, g.url+"/api/v1"+path, &body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "token "+g.token)
+	req.Header.Set("Accept", "application/json")
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s %s: %s", errUnexpectedStatus, method, path, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
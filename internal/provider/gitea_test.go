@@ -0,0 +1,245 @@
+//nolint:lll
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitea answers the few Gitea API endpoints the provider uses for a single repository.
+type fakeGitea struct {
+	mu       sync.Mutex
+	pulls    []map[string]any
+	status   map[string]any
+	reviews  []map[string]any
+	comments []map[string]any
+}
+
+func (f *fakeGitea) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/user", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{"id": 7, "login": "renoglaab"})
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		from := min((page-1)*limit, len(f.pulls))
+		writeJSON(w, f.pulls[from:min(from+limit, len(f.pulls))])
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/commits/{sha}/status", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, f.status)
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls/1/reviews", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, f.reviews)
+	})
+	mux.HandleFunc("POST /api/v1/repos/owner/repo/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		var review map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&review)
+
+		f.mu.Lock()
+		f.reviews = append(f.reviews, map[string]any{
+			"user": map[string]any{"id": 7, "login": "renoglaab"}, "state": review["event"], "commit_id": review["commit_id"], "body": review["body"],
+		})
+		f.mu.Unlock()
+
+		writeJSON(w, map[string]any{})
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, f.comments)
+	})
+	mux.HandleFunc("POST /api/v1/repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+
+		f.mu.Lock()
+		f.comments = append(f.comments, map[string]any{"id": len(f.comments) + 1, "user": map[string]any{"id": 7, "login": "renoglaab"}, "body": comment["body"]})
+		f.mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]any{})
+	})
+	mux.HandleFunc("PATCH /api/v1/repos/owner/repo/issues/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var comment map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+
+		id, _ := strconv.Atoi(r.PathValue("id"))
+
+		f.mu.Lock()
+		f.comments[id-1]["body"] = comment["body"]
+		f.mu.Unlock()
+
+		writeJSON(w, map[string]any{})
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func pull(number int, author string, labels ...string) map[string]any {
+	labelObjects := make([]map[string]any, 0, len(labels))
+	for _, label := range labels {
+		labelObjects = append(labelObjects, map[string]any{"name": label})
+	}
+
+	return map[string]any{
+		"number": number, "title": "Update dependency eslint to v8.2.0", "user": map[string]any{"id": 2, "login": author},
+		"labels": labelObjects, "head": map[string]any{"ref": "renovate/eslint", "sha": "abc"}, "base": map[string]any{"ref": "main"},
+	}
+}
+
+func newFakeGitea(t *testing.T, fake *fakeGitea) *Gitea {
+	t.Helper()
+
+	server := httptest.NewServer(fake.handler())
+	t.Cleanup(server.Close)
+
+	return NewGitea(server.URL+"/", "secret")
+}
+
+func TestGiteaListChangeRequests(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeGitea{}
+	for i := range giteaPageSize + 2 {
+		fake.pulls = append(fake.pulls, pull(i+1, "renovate-bot", "renovate"))
+	}
+
+	fake.pulls = append(fake.pulls, pull(100, "someone", "renovate"), pull(101, "renovate-bot"))
+
+	g := newFakeGitea(t, fake)
+
+	changeRequests, err := g.ListChangeRequests("owner/repo", ListOptions{Author: "renovate-bot", Labels: []string{"renovate"}})
+	require.NoError(t, err)
+	assert.Len(t, changeRequests, giteaPageSize+2)
+
+	cr := changeRequests[0]
+	assert.Equal(t, &ChangeRequest{
+		ID: 1, Title: "Update dependency eslint to v8.2.0", Author: "renovate-bot", SourceBranch: "renovate/eslint",
+		TargetBranch: "main", SHA: "abc", Labels: []string{"renovate"},
+	}, cr)
+}
+
+func TestGiteaCheckStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		status   map[string]any
+		expected CheckState
+	}{
+		{
+			name:     "no statuses",
+			status:   map[string]any{"state": "pending", "total_count": 0},
+			expected: CheckNone,
+		},
+		{
+			name:     "success",
+			status:   map[string]any{"state": "success", "total_count": 2, "statuses": []map[string]any{{"target_url": "https://ci/1"}}},
+			expected: CheckSuccess,
+		},
+		{
+			name:     "pending",
+			status:   map[string]any{"state": "pending", "total_count": 1},
+			expected: CheckPending,
+		},
+		{
+			name:     "failure",
+			status:   map[string]any{"state": "failure", "total_count": 1},
+			expected: CheckFailure,
+		},
+		{
+			name:     "warning",
+			status:   map[string]any{"state": "warning", "total_count": 1},
+			expected: CheckFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := newFakeGitea(t, &fakeGitea{status: tt.status})
+
+			status, err := g.CheckStatus("owner/repo", &ChangeRequest{ID: 1, SHA: "abc"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, status.State)
+		})
+	}
+}
+
+func TestGiteaApprove(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeGitea{reviews: []map[string]any{
+		{"user": map[string]any{"id": 3, "login": "human"}, "state": "APPROVED", "commit_id": "old"},
+		{"user": map[string]any{"id": 4, "login": "dismissed"}, "state": "APPROVED", "commit_id": "abc", "dismissed": true},
+		{"user": map[string]any{"id": 5, "login": "commenter"}, "state": "COMMENT", "commit_id": "abc"},
+	}}
+	g := newFakeGitea(t, fake)
+	cr := &ChangeRequest{ID: 1, SHA: "abc"}
+
+	user, err := g.CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, &User{ID: 7, Username: "renoglaab"}, user)
+
+	approvals, err := g.ListApprovals("owner/repo", cr)
+	require.NoError(t, err)
+	assert.Equal(t, []Approval{{User: User{ID: 3, Username: "human"}, Stale: true}}, approvals)
+
+	require.NoError(t, g.Approve("owner/repo", cr, "Approving merge request! :ship:"))
+
+	approvals, err = g.ListApprovals("owner/repo", cr)
+	require.NoError(t, err)
+	assert.Contains(t, approvals, Approval{User: *user})
+	assert.Equal(t, "Approving merge request! :ship:", fake.reviews[3]["body"])
+}
+
+func TestGiteaComments(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeGitea{}
+	g := newFakeGitea(t, fake)
+	cr := &ChangeRequest{ID: 1}
+
+	require.NoError(t, g.CreateComment("owner/repo", cr, "first"))
+	require.NoError(t, g.CreateComment("owner/repo", cr, "second"))
+	require.NoError(t, g.UpdateComment("owner/repo", cr, 1, "edited"))
+
+	comments, err := g.ListComments("owner/repo", cr)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, "second", comments[0].Body)
+	assert.Equal(t, "edited", comments[1].Body)
+	assert.Equal(t, "renoglaab", comments[1].Author.Username)
+}
+
+func TestGiteaUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer((&fakeGitea{}).handler())
+	t.Cleanup(server.Close)
+
+	_, err := NewGitea(server.URL, "wrong").CurrentUser()
+	assert.ErrorIs(t, err, errUnexpectedStatus)
+}
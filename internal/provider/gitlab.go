@@ -0,0 +1,182 @@
+package provider
+
+import (
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const (
+	gitLabStateOpened  = "opened"
+	gitLabNotesPerPage = 100
+)
+
+// GitLab implements Provider on top of the GitLab API.
+type GitLab struct {
+	client gl.Client
+}
+
+// NewGitLab returns a GitLab provider using client.
+func NewGitLab(client gl.Client) *GitLab {
+	return &GitLab{client: client}
+}
+
+// Name returns "gitlab".
+func (g *GitLab) Name() string {
+	return "gitlab"
+}
+
+// CurrentUser returns the user the API token belongs to.
+func (g *GitLab) CurrentUser() (*User, error) {
+	user, _, err := g.client.CurrentUser()
+	if err != nil {
+		return nil, err
+	}
+
+	return &User{ID: user.ID, Username: user.Username}, nil
+}
+
+// ListChangeRequests lists the open merge requests of a project.
+func (g *GitLab) ListChangeRequests(repo string, opts ListOptions) ([]*ChangeRequest, error) {
+	options := &gitlab.ListProjectMergeRequestsOptions{State: gitlab.Ptr(gitLabStateOpened)}
+
+	if opts.Author != "" {
+		options.AuthorUsername = gitlab.Ptr(opts.Author)
+	}
+
+	if len(opts.Labels) > 0 {
+		labels := gitlab.LabelOptions(opts.Labels)
+		options.Labels = &labels
+	}
+
+	mrs, _, err := g.client.ListProjectMergeRequests(repo, options)
+	if err != nil {
+		return nil, err
+	}
+
+	changeRequests := make([]*ChangeRequest, 0, len(mrs))
+
+	for _, mr := range mrs {
+		cr := &ChangeRequest{
+			ID:           mr.IID,
+			Title:        mr.Title,
+			Description:  mr.Description,
+			SourceBranch: mr.SourceBranch,
+			TargetBranch: mr.TargetBranch,
+			SHA:          mr.SHA,
+			Labels:       mr.Labels,
+			Draft:        mr.Draft,
+			WebURL:       mr.WebURL,
+		}
+
+		if mr.Author != nil {
+			cr.Author = mr.Author.Username
+		}
+
+		changeRequests = append(changeRequests, cr)
+	}
+
+	return changeRequests, nil
+}
+
+// CheckStatus returns the status of the latest pipeline for the head commit.
+func (g *GitLab) CheckStatus(repo string, cr *ChangeRequest) (*CheckStatus, error) {
+	pipelines, _, err := g.client.ListProjectPipelines(repo, &gitlab.ListProjectPipelinesOptions{
+		Ref: gitlab.Ptr(cr.SourceBranch),
+		SHA: gitlab.Ptr(cr.SHA),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pipelines) == 0 {
+		return &CheckStatus{State: CheckNone}, nil
+	}
+
+	pipeline := pipelines[0]
+
+	return &CheckStatus{State: gitLabCheckState(pipeline.Status), Status: pipeline.Status, WebURL: pipeline.WebURL}, nil
+}
+
+func gitLabCheckState(status string) CheckState {
+	switch status {
+	case "success":
+		return CheckSuccess
+	case "created", "waiting_for_resource", "preparing", "pending", "running", "scheduled":
+		return CheckPending
+	default:
+		return CheckFailure
+	}
+}
+
+// ListApprovals returns the approvals of a merge request.
+// GitLab resets approvals on push if the project is configured to, so they are never stale.
+func (g *GitLab) ListApprovals(repo string, cr *ChangeRequest) ([]Approval, error) {
+	approvals, _, err := g.client.GetMergeRequestApprovals(repo, cr.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Approval, 0, len(approvals.ApprovedBy))
+
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil {
+			result = append(result, Approval{User: User{ID: approver.User.ID, Username: approver.User.Username}})
+		}
+	}
+
+	return result, nil
+}
+
+// Approve approves a merge request at its head commit and posts body as a note.
+func (g *GitLab) Approve(repo string, cr *ChangeRequest, body string) error {
+	_, _, err := g.client.ApproveMergeRequest(repo, cr.ID, &gitlab.ApproveMergeRequestOptions{SHA: gitlab.Ptr(cr.SHA)})
+	if err != nil {
+		return err
+	}
+
+	if body == "" {
+		return nil
+	}
+
+	return g.CreateComment(repo, cr, body)
+}
+
+// ListComments returns the most recent notes of a merge request, newest first.
+func (g *GitLab) ListComments(repo string, cr *ChangeRequest) ([]*Comment, error) {
+	notes, _, err := g.client.ListMergeRequestNotes(repo, cr.ID, &gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: gitLabNotesPerPage},
+		OrderBy:     gitlab.Ptr("created_at"),
+		Sort:        gitlab.Ptr("desc"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make([]*Comment, 0, len(notes))
+	for _, note := range notes {
+		comments = append(comments, &Comment{
+			ID:     note.ID,
+			Author: User{ID: note.Author.ID, Username: note.Author.Username},
+			Body:   note.Body,
+			System: note.System,
+		})
+	}
+
+	return comments, nil
+}
+
+// CreateComment adds a note to a merge request.
+func (g *GitLab) CreateComment(repo string, cr *ChangeRequest, body string) error {
+	_, _, err := g.client.CreateMergeRequestNote(repo, cr.ID, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.Ptr(body)})
+
+	return err
+}
+
+// UpdateComment changes a note of a merge request.
+func (g *GitLab) UpdateComment(repo string, cr *ChangeRequest, commentID int64, body string) error {
+	_, _, err := g.client.UpdateMergeRequestNote(repo, cr.ID, commentID, &gitlab.UpdateMergeRequestNoteOptions{
+		Body: gitlab.Ptr(body),
+	})
+
+	return err
+}
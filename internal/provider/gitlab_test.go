@@ -0,0 +1,139 @@
+//nolint:lll
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gl "github.com/xMoelletschi/renoglaab/internal/gitlab"
+)
+
+func newFakeGitLab(t *testing.T, handler http.Handler) *GitLab {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := gl.CreateGitLabClient("secret", server.URL)
+	require.NoError(t, err)
+
+	return NewGitLab(client)
+}
+
+func TestGitLabListChangeRequests(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/projects/group%2Fproject/merge_requests", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "opened", r.URL.Query().Get("state"))
+		assert.Equal(t, "renovate-bot", r.URL.Query().Get("author_username"))
+		assert.Equal(t, "renovate", r.URL.Query().Get("labels"))
+
+		writeJSON(w, []map[string]any{{
+			"iid": 3, "title": "Update dependency eslint to v8.2.0", "source_branch": "renovate/eslint", "target_branch": "main",
+			"sha": "abc", "labels": []string{"renovate"}, "draft": true, "author": map[string]any{"username": "renovate-bot"},
+		}})
+	})
+
+	g := newFakeGitLab(t, mux)
+
+	changeRequests, err := g.ListChangeRequests("group/project", ListOptions{Author: "renovate-bot", Labels: []string{"renovate"}})
+	require.NoError(t, err)
+	assert.Equal(t, []*ChangeRequest{{
+		ID: 3, Title: "Update dependency eslint to v8.2.0", Author: "renovate-bot", SourceBranch: "renovate/eslint",
+		TargetBranch: "main", SHA: "abc", Labels: []string{"renovate"}, Draft: true,
+	}}, changeRequests)
+}
+
+func TestGitLabCheckStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		pipelines []map[string]any
+		expected  CheckState
+	}{
+		{
+			name:     "no pipeline",
+			expected: CheckNone,
+		},
+		{
+			name:      "success",
+			pipelines: []map[string]any{{"id": 1, "status": "success"}},
+			expected:  CheckSuccess,
+		},
+		{
+			name:      "running",
+			pipelines: []map[string]any{{"id": 1, "status": "running"}},
+			expected:  CheckPending,
+		},
+		{
+			name:      "canceled",
+			pipelines: []map[string]any{{"id": 1, "status": "canceled"}},
+			expected:  CheckFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := newFakeGitLab(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "abc", r.URL.Query().Get("sha"))
+
+				writeJSON(w, append([]map[string]any{}, tt.pipelines...))
+			}))
+
+			status, err := g.CheckStatus("group/project", &ChangeRequest{ID: 3, SourceBranch: "renovate/eslint", SHA: "abc"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, status.State)
+		})
+	}
+}
+
+func TestGitLabApprove(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		approved string
+		notes    []string
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v4/projects/group%2Fproject/merge_requests/3/approve", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		mu.Lock()
+		approved = body["sha"]
+		mu.Unlock()
+
+		writeJSON(w, map[string]any{})
+	})
+	mux.HandleFunc("POST /api/v4/projects/group%2Fproject/merge_requests/3/notes", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		mu.Lock()
+		notes = append(notes, body["body"])
+		mu.Unlock()
+
+		writeJSON(w, map[string]any{})
+	})
+
+	g := newFakeGitLab(t, mux)
+	cr := &ChangeRequest{ID: 3, SHA: "abc"}
+
+	require.NoError(t, g.Approve("group/project", cr, ""))
+	assert.Equal(t, "abc", approved)
+	assert.Empty(t, notes)
+
+	require.NoError(t, g.Approve("group/project", cr, "Approving merge request! :ship:"))
+	assert.Equal(t, []string{"Approving merge request! :ship:"}, notes)
+}
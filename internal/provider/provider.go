@@ -0,0 +1,80 @@
+// Package provider describes the code-hosting platforms renoglaab works with
+// in terms that don't depend on a single platform's API.
+package provider
+
+// Check states of a change request's head commit.
+const (
+	CheckNone    CheckState = "none"
+	CheckPending CheckState = "pending"
+	CheckSuccess CheckState = "success"
+	CheckFailure CheckState = "failure"
+)
+
+// CheckState summarizes the CI result of a commit.
+type CheckState string
+
+// ChangeRequest is a proposed change, i.e. a GitLab merge request or a Gitea pull request.
+type ChangeRequest struct {
+	// ID is the number of the change request within its repository, e.g. the MR IID.
+	ID           int64
+	Title        string
+	Description  string
+	Author       string
+	SourceBranch string
+	TargetBranch string
+	SHA          string
+	Labels       []string
+	Draft        bool
+	WebURL       string
+}
+
+// CheckStatus is the CI result of a change request's head commit.
+type CheckStatus struct {
+	State CheckState
+	// Status is the platform's own status, e.g. "success" or "canceled".
+	Status string
+	WebURL string
+}
+
+// User is an account on the platform.
+type User struct {
+	ID       int64
+	Username string
+}
+
+// Approval is an approval of a change request.
+type Approval struct {
+	User User
+	// Stale is set if the approval was given for an older head commit.
+	Stale bool
+}
+
+// Comment is a comment on a change request.
+type Comment struct {
+	ID     int64
+	Author User
+	Body   string
+	// System is set for comments generated by the platform itself.
+	System bool
+}
+
+// ListOptions narrows down the open change requests of a repository.
+type ListOptions struct {
+	Author string
+	Labels []string
+}
+
+// Provider is a code-hosting platform.
+type Provider interface {
+	// Name identifies the platform, e.g. in logs.
+	Name() string
+	CurrentUser() (*User, error)
+	ListChangeRequests(repo string, opts ListOptions) ([]*ChangeRequest, error)
+	CheckStatus(repo string, cr *ChangeRequest) (*CheckStatus, error)
+	ListApprovals(repo string, cr *ChangeRequest) ([]Approval, error)
+	// Approve approves the change request at its head commit. A non-empty body is posted along with it.
+	Approve(repo string, cr *ChangeRequest, body string) error
+	ListComments(repo string, cr *ChangeRequest) ([]*Comment, error)
+	CreateComment(repo string, cr *ChangeRequest, body string) error
+	UpdateComment(repo string, cr *ChangeRequest, commentID int64, body string) error
+}
@@ -0,0 +1,258 @@
+package renovate
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Update types as reported by Renovate.
+const (
+	UpdateTypeMajor  = "major"
+	UpdateTypeMinor  = "minor"
+	UpdateTypePatch  = "patch"
+	UpdateTypeDigest = "digest"
+	UpdateTypePin    = "pin"
+)
+
+// Update describes a single dependency update of a Renovate merge request.
+type Update struct {
+	Package    string `json:"package"`
+	DepType    string `json:"depType"`
+	UpdateType string `json:"updateType"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+// Info holds the updates parsed from a Renovate merge request.
+type Info struct {
+	Updates []Update `json:"updates"`
+}
+
+var (
+	markdownLinkRegex = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	codeSpanRegex     = regexp.MustCompile("`([^`]*)`")
+	titleRegex        = regexp.MustCompile(
+		`(?i)update (?:dependency |module |plugin )?(\S+)(?: docker tag| digest)? to (\S+)`,
+	)
+	versionRegex = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+)
+
+// Parse extracts the dependency updates from a Renovate merge request.
+// The update table in the description is preferred, the title is used as a fallback.
+func Parse(title, description string) Info {
+	if updates := parseTable(description); len(updates) > 0 {
+		return Info{Updates: updates}
+	}
+
+	if matches := titleRegex.FindStringSubmatch(title); matches != nil {
+		to := strings.TrimSuffix(matches[2], ",")
+
+		return Info{Updates: []Update{{Package: matches[1], To: to, UpdateType: updateTypeFromTitle(title)}}}
+	}
+
+	return Info{}
+}
+
+// Packages returns the names of all updated packages.
+func (i Info) Packages() []string {
+	packages := make([]string, 0, len(i.Updates))
+	for _, update := range i.Updates {
+		packages = append(packages, update.Package)
+	}
+
+	return packages
+}
+
+// UpdateTypes returns the distinct update types of all updates.
+func (i Info) UpdateTypes() []string {
+	var types []string
+
+	seen := map[string]bool{}
+
+	for _, update := range i.Updates {
+		if update.UpdateType != "" && !seen[update.UpdateType] {
+			seen[update.UpdateType] = true
+			types = append(types, update.UpdateType)
+		}
+	}
+
+	return types
+}
+
+// parseTable parses the markdown table Renovate adds to the merge request description.
+func parseTable(description string) []Update {
+	var (
+		updates []Update
+		columns map[string]int
+	)
+
+	for line := range strings.SplitSeq(description, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			columns = nil
+
+			continue
+		}
+
+		cells := splitRow(line)
+
+		if columns == nil {
+			columns = parseHeader(cells)
+
+			continue
+		}
+
+		if isSeparatorRow(cells) {
+			continue
+		}
+
+		if update, ok := parseRow(cells, columns); ok {
+			updates = append(updates, update)
+		}
+	}
+
+	return updates
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimPrefix(strings.TrimSuffix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+
+	return cells
+}
+
+// parseHeader maps the lower-cased column names to their index.
+// Tables without a package column are ignored.
+func parseHeader(cells []string) map[string]int {
+	columns := map[string]int{}
+	for i, cell := range cells {
+		columns[strings.ToLower(cell)] = i
+	}
+
+	if _, ok := columns["package"]; !ok {
+		return map[string]int{}
+	}
+
+	return columns
+}
+
+func isSeparatorRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.Trim(cell, ":-") != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseRow(cells []string, columns map[string]int) (Update, bool) {
+	cell := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(cells) {
+			return cells[i]
+		}
+
+		return ""
+	}
+
+	pkg := packageName(cell("package"))
+	if pkg == "" {
+		return Update{}, false
+	}
+
+	update := Update{
+		Package:    pkg,
+		DepType:    stripMarkdown(cell("type")),
+		UpdateType: strings.ToLower(stripMarkdown(cell("update"))),
+	}
+
+	versions := codeSpanRegex.FindAllStringSubmatch(cell("change"), -1)
+	if len(versions) == 2 { //nolint:mnd
+		update.From, update.To = versions[0][1], versions[1][1]
+	} else if len(versions) == 1 {
+		update.To = versions[0][1]
+	}
+
+	if update.UpdateType == "" {
+		update.UpdateType = UpdateType(update.From, update.To)
+	}
+
+	return update, true
+}
+
+// packageName extracts the package name from a table cell like "[name](url) ([source](url))".
+func packageName(cell string) string {
+	if matches := markdownLinkRegex.FindStringSubmatch(cell); matches != nil {
+		return strings.TrimSpace(matches[1])
+	}
+
+	name, _, _ := strings.Cut(cell, " (")
+
+	return strings.TrimSpace(name)
+}
+
+func stripMarkdown(cell string) string {
+	cell = markdownLinkRegex.ReplaceAllString(cell, "$1")
+
+	return strings.Trim(cell, "`* ")
+}
+
+func updateTypeFromTitle(title string) string {
+	lower := strings.ToLower(title)
+
+	switch {
+	case strings.Contains(lower, "digest"):
+		return UpdateTypeDigest
+	case strings.HasPrefix(lower, "pin ") || strings.Contains(lower, " pin "):
+		return UpdateTypePin
+	case strings.Contains(lower, "major"):
+		return UpdateTypeMajor
+	}
+
+	return ""
+}
+
+// UpdateType derives the semantic version update type from two versions.
+// It returns an empty string if either version cannot be parsed.
+func UpdateType(from, to string) string {
+	fromParts, ok := parseVersion(from)
+	if !ok {
+		return ""
+	}
+
+	toParts, ok := parseVersion(to)
+	if !ok {
+		return ""
+	}
+
+	switch {
+	case fromParts[0] != toParts[0]:
+		return UpdateTypeMajor
+	case fromParts[1] != toParts[1]:
+		return UpdateTypeMinor
+	default:
+		return UpdateTypePatch
+	}
+}
+
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+
+	matches := versionRegex.FindStringSubmatch(strings.TrimSpace(version))
+	if matches == nil {
+		return parts, false
+	}
+
+	for i := range parts {
+		if matches[i+1] != "" {
+			parts[i], _ = strconv.Atoi(matches[i+1])
+		}
+	}
+
+	return parts, true
+}
@@ -0,0 +1,94 @@
+//nolint:lll,funlen
+package renovate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xMoelletschi/renoglaab/internal/renovate"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		title       string
+		description string
+		expected    renovate.Info
+	}{
+		{
+			name:  "Table with type and update columns",
+			title: "Update all non-major dependencies",
+			description: `This MR contains the following updates:
+
+| Package | Type | Update | Change |
+|---|---|---|---|
+| [eslint](https://eslint.org) ([source](https://github.com/eslint/eslint)) | devDependencies | minor | ` + "`8.1.0` -> `8.2.0`" + ` |
+| [react](https://react.dev) | dependencies | patch | ` + "`18.2.0` → `18.2.1`" + ` |
+
+---
+`,
+			expected: renovate.Info{Updates: []renovate.Update{
+				{Package: "eslint", DepType: "devDependencies", UpdateType: "minor", From: "8.1.0", To: "8.2.0"},
+				{Package: "react", DepType: "dependencies", UpdateType: "patch", From: "18.2.0", To: "18.2.1"},
+			}},
+		},
+		{
+			name:  "Table without update column",
+			title: "fix(deps): update module github.com/stretchr/testify to v1.8.1",
+			description: `| Package | Change | Age |
+|---|---|---|
+| [github.com/stretchr/testify](https://github.com/stretchr/testify) | ` + "`v1.8.0` -> `v1.8.1`" + ` | [![age](https://example.com)](https://example.com) |
+`,
+			expected: renovate.Info{Updates: []renovate.Update{
+				{Package: "github.com/stretchr/testify", UpdateType: "patch", From: "v1.8.0", To: "v1.8.1"},
+			}},
+		},
+		{
+			name:     "Title fallback",
+			title:    "chore(deps): update golang docker tag to v1.22",
+			expected: renovate.Info{Updates: []renovate.Update{{Package: "golang", To: "v1.22"}}},
+		},
+		{
+			name:     "Digest title fallback",
+			title:    "Update alpine digest to 865b95f",
+			expected: renovate.Info{Updates: []renovate.Update{{Package: "alpine", To: "865b95f", UpdateType: "digest"}}},
+		},
+		{
+			name:     "Nothing to parse",
+			title:    "Lock file maintenance",
+			expected: renovate.Info{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, renovate.Parse(tt.title, tt.description))
+		})
+	}
+}
+
+func TestUpdateType(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, renovate.UpdateTypeMajor, renovate.UpdateType("1.2.3", "2.0.0"))
+	assert.Equal(t, renovate.UpdateTypeMinor, renovate.UpdateType("v1.2.3", "v1.3.0"))
+	assert.Equal(t, renovate.UpdateTypePatch, renovate.UpdateType("1.2.3", "1.2.4"))
+	assert.Empty(t, renovate.UpdateType("abc", "1.2.4"))
+}
+
+func TestInfoHelpers(t *testing.T) {
+	t.Parallel()
+
+	info := renovate.Info{Updates: []renovate.Update{
+		{Package: "a", UpdateType: "patch"},
+		{Package: "b", UpdateType: "patch"},
+		{Package: "c", UpdateType: "minor"},
+	}}
+
+	assert.Equal(t, []string{"a", "b", "c"}, info.Packages())
+	assert.Equal(t, []string{"patch", "minor"}, info.UpdateTypes())
+}
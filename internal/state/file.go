@@ -0,0 +1,46 @@
+package state
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileBackend stores the state in a local JSON file.
+type FileBackend struct {
+	Path string
+}
+
+// Load reads the state file.
+func (b *FileBackend) Load() ([]byte, error) {
+	content, err := os.ReadFile(b.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return content, err
+}
+
+// Save replaces the state file atomically,
+// so an interrupted run never leaves a truncated state behind.
+func (b *FileBackend) Save(content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(b.Path), filepath.Base(b.Path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close() //nolint:errcheck,gosec
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), b.Path)
+}
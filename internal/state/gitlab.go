@@ -0,0 +1,61 @@
+package state
+
+import (
+	"errors"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// VariableClient is the part of the GitLab API used by GitLabBackend.
+type VariableClient interface {
+	GetProjectVariable(repo, key string) (*gitlab.ProjectVariable, *gitlab.Response, error)
+	CreateProjectVariable(
+		repo string, opts *gitlab.CreateProjectVariableOptions,
+	) (*gitlab.ProjectVariable, *gitlab.Response, error)
+	UpdateProjectVariable(
+		repo, key string, opts *gitlab.UpdateProjectVariableOptions,
+	) (*gitlab.ProjectVariable, *gitlab.Response, error)
+}
+
+// GitLabBackend stores the state in a CI/CD variable of a GitLab project,
+// e.g. the project running renoglaab on a schedule.
+type GitLabBackend struct {
+	Client  VariableClient
+	Project string
+	Key     string
+}
+
+// Load reads the variable.
+func (b *GitLabBackend) Load() ([]byte, error) {
+	variable, _, err := b.Client.GetProjectVariable(b.Project, b.Key)
+	if errors.Is(err, gitlab.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(variable.Value), nil
+}
+
+// Save updates the variable, creating it on the first run.
+// The variable is raw, so GitLab doesn't expand anything in the JSON.
+func (b *GitLabBackend) Save(content []byte) error {
+	_, _, err := b.Client.UpdateProjectVariable(b.Project, b.Key, &gitlab.UpdateProjectVariableOptions{
+		Value: gitlab.Ptr(string(content)),
+		Raw:   gitlab.Ptr(true),
+	})
+	if !errors.Is(err, gitlab.ErrNotFound) {
+		return err
+	}
+
+	_, _, err = b.Client.CreateProjectVariable(b.Project, &gitlab.CreateProjectVariableOptions{
+		Key:         gitlab.Ptr(b.Key),
+		Value:       gitlab.Ptr(string(content)),
+		Description: gitlab.Ptr("renoglaab state, managed automatically"),
+		Raw:         gitlab.Ptr(true),
+	})
+
+	return err
+}
@@ -0,0 +1,65 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// fakeVariables keeps project variables in memory and answers like the GitLab API.
+type fakeVariables struct {
+	variables map[string]*gitlab.ProjectVariable
+}
+
+func (f *fakeVariables) GetProjectVariable(repo, key string) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	variable, ok := f.variables[repo+"/"+key]
+	if !ok {
+		return nil, nil, gitlab.ErrNotFound
+	}
+
+	return variable, nil, nil
+}
+
+func (f *fakeVariables) CreateProjectVariable(repo string, opts *gitlab.CreateProjectVariableOptions) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	variable := &gitlab.ProjectVariable{Key: *opts.Key, Value: *opts.Value, Raw: *opts.Raw}
+	f.variables[repo+"/"+variable.Key] = variable
+
+	return variable, nil, nil
+}
+
+func (f *fakeVariables) UpdateProjectVariable(repo, key string, opts *gitlab.UpdateProjectVariableOptions) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	variable, ok := f.variables[repo+"/"+key]
+	if !ok {
+		return nil, nil, gitlab.ErrNotFound
+	}
+
+	variable.Value = *opts.Value
+
+	return variable, nil, nil
+}
+
+func TestGitLabBackend(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeVariables{variables: map[string]*gitlab.ProjectVariable{}}
+	backend := &GitLabBackend{Client: fake, Project: "group/renoglaab", Key: "RENOGLAAB_STATE"}
+
+	store, err := Open(backend)
+	require.NoError(t, err)
+
+	store.SetProject("group/project", Project{ConsecutiveFailures: 1})
+	require.NoError(t, store.Save())
+
+	variable := fake.variables["group/renoglaab/RENOGLAAB_STATE"]
+	require.NotNil(t, variable)
+	assert.True(t, variable.Raw)
+
+	store.SetProject("group/project", Project{ConsecutiveFailures: 2})
+	require.NoError(t, store.Save())
+
+	reopened, err := Open(backend)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reopened.Project("group/project").ConsecutiveFailures)
+}
@@ -0,0 +1,142 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat    = "20060102T150405Z"
+	amzDayFormat     = "20060102"
+	s3SigningService = "s3"
+)
+
+var errUnexpectedStatus = errors.New("unexpected status")
+
+// S3Backend stores the state as an object in an S3-compatible bucket, e.g. AWS S3 or MinIO.
+// Requests use path-style URLs and are signed with AWS Signature Version 4.
+type S3Backend struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Key             string
+	AccessKeyID     string
+	SecretAccessKey string
+	Client          *http.Client
+}
+
+// Load downloads the object.
+func (b *S3Backend) Load() ([]byte, error) {
+	resp, err := b.do(http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w loading state from %s: %s", errUnexpectedStatus, resp.Request.URL, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// Save uploads the object.
+func (b *S3Backend) Save(content []byte) error {
+	resp, err := b.do(http.MethodPut, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w saving state to %s: %s", errUnexpectedStatus, resp.Request.URL, resp.Status)
+	}
+
+	return nil
+}
+
+func (b *S3Backend) do(method string, body []byte) (*http.Response, error) {
+	segments := strings.Split(b.Key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	target := strings.TrimSuffix(b.Endpoint, "/") + "/" + url.PathEscape(b.Bucket) + "/" + strings.Join(segments, "/")
+
+	req, err := http.NewRequestWithContext(context.Background(), method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	b.sign(req, body, time.Now().UTC())
+
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 headers to a request.
+func (b *S3Backend) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format(amzDateFormat)
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(amzDayFormat), b.Region, s3SigningService, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + b.SecretAccessKey)
+	for _, part := range []string{now.Format(amzDayFormat), b.Region, s3SigningService, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign)),
+	))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
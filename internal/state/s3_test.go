@@ -0,0 +1,105 @@
+package state
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible server such as MinIO.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minioadmin/") {
+		w.WriteHeader(http.StatusForbidden)
+
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_, _ = w.Write(object)
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		f.objects[r.URL.Path] = body
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Backend(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	backend := &S3Backend{
+		Endpoint: server.URL, Region: "us-east-1", Bucket: "renoglaab", Key: "state/state.json",
+		AccessKeyID: "minioadmin", SecretAccessKey: "minioadmin",
+	}
+
+	_, err := backend.Load()
+	require.ErrorIs(t, err, ErrNotFound)
+
+	store, err := Open(backend)
+	require.NoError(t, err)
+
+	store.SetProject("group/project", Project{ConsecutiveFailures: 2})
+	require.NoError(t, store.Save())
+	assert.Contains(t, fake.objects, "/renoglaab/state/state.json")
+
+	reopened, err := Open(backend)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reopened.Project("group/project").ConsecutiveFailures)
+
+	backend.AccessKeyID = "wrong"
+	assert.Error(t, backend.Save([]byte("{}")))
+}
+
+func TestS3BackendSign(t *testing.T) {
+	t.Parallel()
+
+	backend := &S3Backend{
+		Endpoint: "http://minio.test:9000", Region: "us-east-1", Bucket: "renoglaab", Key: "state/state.json",
+		AccessKeyID: "minioadmin", SecretAccessKey: "minioadmin",
+	}
+	body := []byte(`{"projects":{}}`)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPut, "http://minio.test:9000/renoglaab/state/state.json", nil)
+	require.NoError(t, err)
+
+	backend.sign(req, body, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "20240501T120000Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=minioadmin/20240501/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, "+
+			"Signature=06df05ec28763525a4cdce29f53e53d4c3a10a66e21b3f43c37d97abc4fd61df",
+		req.Header.Get("Authorization"))
+}
@@ -0,0 +1,176 @@
+// Package state keeps what renoglaab has to remember between runs.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Project is the state kept for a single project.
+type Project struct {
+	// ConsecutiveFailures counts the failed actions since the last run without failures.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// Paused is set while the circuit breaker of the project is open.
+	Paused       bool      `json:"paused,omitempty"`
+	PausedReason string    `json:"pausedReason,omitempty"`
+	PausedAt     time.Time `json:"pausedAt,omitzero"`
+	// MergeRequests holds the state of the merge requests renoglaab worked on, by IID.
+	MergeRequests map[int64]MergeRequest `json:"mergeRequests,omitempty"`
+}
+
+// MergeRequest is the state kept for a single merge request.
+type MergeRequest struct {
+	// Retries counts the pipeline retries for the head commit RetriedSHA.
+	Retries    int    `json:"retries,omitempty"`
+	RetriedSHA string `json:"retriedSha,omitempty"`
+	// TriggeredAt is when a pipeline was last triggered for the head commit TriggeredSHA.
+	TriggeredAt  time.Time `json:"triggeredAt,omitzero"`
+	TriggeredSHA string    `json:"triggeredSha,omitempty"`
+	// Notes are the notes renoglaab posted on the merge request, by kind.
+	Notes map[string]Note `json:"notes,omitempty"`
+	// UpdatedAt is when the state of the merge request last changed.
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+}
+
+// Note is a note renoglaab posted on a merge request.
+type Note struct {
+	ID int64 `json:"id"`
+	// SHA is the commit the note is about, the head commit or, after a merge, the merge commit.
+	SHA string `json:"sha"`
+	// Digest identifies the body, to tell whether the note has to be updated.
+	Digest string `json:"digest,omitempty"`
+}
+
+// mergeRequestRetention is how long the state of a merge request is kept after its last change.
+const mergeRequestRetention = 30 * 24 * time.Hour
+
+// ErrNotFound is returned by a backend if no state has been stored yet.
+var ErrNotFound = errors.New("no state stored")
+
+// Backend persists the serialized state.
+type Backend interface {
+	// Load returns the stored state or ErrNotFound.
+	Load() ([]byte, error)
+	// Save replaces the stored state.
+	Save(content []byte) error
+}
+
+type data struct {
+	Projects map[string]Project `json:"projects"`
+}
+
+// Store holds the state of all projects. It is safe for concurrent use.
+type Store struct {
+	backend Backend
+	mu      sync.Mutex
+	data    data
+}
+
+// Open loads the state from a backend. Nothing stored yet yields an empty state.
+// Without a backend the state is only kept in memory, i.e. for the lifetime of the process.
+func Open(backend Backend) (*Store, error) {
+	store := &Store{backend: backend, data: data{Projects: map[string]Project{}}}
+
+	if backend == nil {
+		return store, nil
+	}
+
+	content, err := backend.Load()
+	if errors.Is(err, ErrNotFound) {
+		return store, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &store.data); err != nil {
+		return nil, err
+	}
+
+	if store.data.Projects == nil {
+		store.data.Projects = map[string]Project{}
+	}
+
+	return store, nil
+}
+
+// Project returns the state of a project.
+func (s *Store) Project(repo string) Project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.data.Projects[repo]
+	project.MergeRequests = maps.Clone(project.MergeRequests)
+
+	return project
+}
+
+// SetProject replaces the state of a project.
+func (s *Store) SetProject(repo string, project Project) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Projects[repo] = project
+}
+
+// MergeRequest returns the state of a merge request.
+func (s *Store) MergeRequest(repo string, iid int64) MergeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mr := s.data.Projects[repo].MergeRequests[iid]
+	mr.Notes = maps.Clone(mr.Notes)
+
+	return mr
+}
+
+// SetMergeRequest replaces the state of a merge request.
+func (s *Store) SetMergeRequest(repo string, iid int64, mr MergeRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.data.Projects[repo]
+	if project.MergeRequests == nil {
+		project.MergeRequests = map[int64]MergeRequest{}
+	}
+
+	mr.UpdatedAt = time.Now()
+	project.MergeRequests[iid] = mr
+	s.data.Projects[repo] = project
+}
+
+// Save writes the state to its backend. The state of merge requests that didn't change
+// within mergeRequestRetention is dropped first, so closed merge requests don't pile up.
+func (s *Store) Save() error {
+	s.prune(time.Now().Add(-mergeRequestRetention))
+
+	if s.backend == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	content, err := json.MarshalIndent(s.data, "", "  ")
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return s.backend.Save(content)
+}
+
+// prune drops the state of merge requests last changed before the given time.
+func (s *Store) prune(before time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, project := range s.data.Projects {
+		maps.DeleteFunc(project.MergeRequests, func(_ int64, mr MergeRequest) bool {
+			return mr.UpdatedAt.Before(before)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := Open(&FileBackend{Path: path})
+	require.NoError(t, err)
+	assert.Equal(t, Project{}, store.Project("group/project"))
+
+	pausedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.SetProject("group/project", Project{ConsecutiveFailures: 3, Paused: true, PausedReason: "broken", PausedAt: pausedAt})
+	require.NoError(t, store.Save())
+
+	reopened, err := Open(&FileBackend{Path: path})
+	require.NoError(t, err)
+	assert.Equal(t, Project{ConsecutiveFailures: 3, Paused: true, PausedReason: "broken", PausedAt: pausedAt}, reopened.Project("group/project"))
+}
+
+func TestOpenInMemory(t *testing.T) {
+	t.Parallel()
+
+	store, err := Open(nil)
+	require.NoError(t, err)
+
+	store.SetProject("group/project", Project{ConsecutiveFailures: 1})
+	require.NoError(t, store.Save())
+	assert.Equal(t, 1, store.Project("group/project").ConsecutiveFailures)
+}
+
+func TestOpenInvalidFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+	_, err := Open(&FileBackend{Path: path})
+	assert.Error(t, err)
+}
+
+func TestMergeRequestState(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := Open(&FileBackend{Path: path})
+	require.NoError(t, err)
+	assert.Equal(t, MergeRequest{}, store.MergeRequest("group/project", 1))
+
+	store.SetMergeRequest("group/project", 1, MergeRequest{Retries: 1, RetriedSHA: "abc", Notes: map[string]Note{"retry": {ID: 7, SHA: "abc"}}})
+	store.SetProject("group/project", Project{ConsecutiveFailures: 2, MergeRequests: store.Project("group/project").MergeRequests})
+
+	// Merge requests that didn't change for a while are dropped on save.
+	store.data.Projects["group/project"].MergeRequests[2] = MergeRequest{Retries: 2, UpdatedAt: time.Now().Add(-2 * mergeRequestRetention)}
+
+	require.NoError(t, store.Save())
+
+	reopened, err := Open(&FileBackend{Path: path})
+	require.NoError(t, err)
+
+	project := reopened.Project("group/project")
+	assert.Equal(t, 2, project.ConsecutiveFailures)
+	assert.Len(t, project.MergeRequests, 1)
+
+	mr := reopened.MergeRequest("group/project", 1)
+	assert.Equal(t, 1, mr.Retries)
+	assert.Equal(t, Note{ID: 7, SHA: "abc"}, mr.Notes["retry"])
+}